@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// automapMode answers an autofs program map lookup: given a key it prints
+// the map entry that mounts the matching host over sshfs, so the kernel
+// mounts hosts on first access instead of the daemon keeping them all up.
+//
+// autofs runs the map program with the key as its only argument, which
+// leaves no room for the command name or flags; point the map at a wrapper
+// script such as
+//
+//	#!/bin/sh
+//	exec /usr/local/bin/sshfs-connector automap --hosts /etc/sshfs_hosts.txt "$1"
+//
+// The key may also be given on stdin. It matches an IP, user@ip, or the last
+// element of a host's mount path. An unknown key prints nothing and exits
+// non-zero, which autofs treats as a missing entry.
+func automapMode(args []string) int {
+	var key string
+	switch len(args) {
+	case 0:
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		key = strings.TrimSpace(line)
+	case 1:
+		key = args[0]
+	}
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "Usage: ./sshfs-connector automap <key>")
+		return EXIT_USAGE
+	}
+
+	host, err := findAutomapHost(key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return EXIT_UNKNOWN_HOST
+	}
+	fmt.Println(automapEntry(host))
+	return EXIT_MOUNTED
+}
+
+func findAutomapHost(key string) (sshfsmon.Host, error) {
+	if hosts, err := findHosts(key); err == nil {
+		return hosts[0], nil
+	}
+
+	hosts, err := loadHosts()
+	if err != nil {
+		return sshfsmon.Host{}, err
+	}
+	for _, host := range hosts {
+		if filepath.Base(host.MountPath) == key {
+			return host, nil
+		}
+	}
+	return sshfsmon.Host{}, fmt.Errorf("no host for key %s in %s", key, hostsSource)
+}
+
+// automapEntry formats an autofs map entry for the host, with the same sshfs
+// options a regular mount would use. The # and : of the location are escaped
+// for the map parser.
+func automapEntry(host sshfsmon.Host) string {
+	return fmt.Sprintf("-fstype=fuse,rw,nodev,%s :sshfs\\#%s@%s\\:%s",
+		monitor.MountOptions(host), host.Username, host.IP, host.RemoteDir)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"sshfs-connector/sshfsmon"
+)
+
+const (
+	BENCH_SIZE  = 4       // MB written and read back per host, --bench-size
+	BENCH_CHUNK = 1 << 20 // write size; random, so ssh compression cannot shrink it
+	BENCH_STATS = 10      // stat calls averaged for the latency
+)
+
+// benchResult is one host's row of the benchmark table.
+type benchResult struct {
+	host      sshfsmon.Host
+	mountTime time.Duration // 0 when the host was already mounted
+	write     float64       // MB/s
+	read      float64       // MB/s
+	stat      time.Duration
+	err       error
+}
+
+// benchmarkMode mounts every host (or the ones matching args[0]) that is
+// not mounted yet, writes a temporary file of --bench-size MB into each mount,
+// reads it back and stats it, and prints a table of the results. It only
+// ever runs when asked for; nothing else in the tool writes to a mount.
+func benchmarkMode(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "Usage: ./sshfs-connector benchmark [ip|user@ip]")
+		return EXIT_USAGE
+	}
+
+	var hosts []sshfsmon.Host
+	var err error
+	if len(args) == 1 {
+		if hosts, err = findHosts(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return EXIT_UNKNOWN_HOST
+		}
+	} else if hosts, err = loadHosts(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading hosts: %v\n", err)
+		return EXIT_MOUNT_FAILED
+	}
+	if !requireMountAccess(hosts) {
+		return EXIT_MOUNT_FAILED
+	}
+
+	data := make([]byte, BENCH_CHUNK)
+	if _, err := rand.Read(data); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return EXIT_MOUNT_FAILED
+	}
+
+	// One host at a time, so the hosts do not compete for the local link
+	exitCode := EXIT_MOUNTED
+	var rows []benchResult
+	for _, host := range hosts {
+		row, code := benchmarkHost(host, data)
+		rows = append(rows, row)
+		if code > exitCode {
+			exitCode = code
+		}
+	}
+	monitor.WaitHooks()
+
+	printBenchmark(rows)
+	return exitCode
+}
+
+func benchmarkHost(host sshfsmon.Host, data []byte) (benchResult, int) {
+	row := benchResult{host: host}
+	result := monitor.MountHost(host)
+	switch {
+	case !result.Reachable:
+		row.err = fmt.Errorf("not reachable")
+		return row, EXIT_UNREACHABLE
+	case !result.Mounted:
+		row.err = fmt.Errorf("mount failed: %v", result.Error)
+		return row, EXIT_MOUNT_FAILED
+	}
+	mountedHere := result.ExecutedCmd != sshfsmon.ALREADY_MOUNTED
+	if mountedHere {
+		row.mountTime = result.MountTime
+	}
+
+	row.err = measureIO(host.MountPath, data, &row)
+
+	if mountedHere && benchUnmount {
+		if err := monitor.Unmount(host.MountPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to unmount %s: %v\n", host.MountPath, err)
+		} else {
+			monitor.RunHook(config.PostUnmountHook, "post-unmount", host)
+		}
+	}
+	if row.err != nil {
+		return row, EXIT_MOUNT_FAILED
+	}
+	return row, EXIT_MOUNTED
+}
+
+// measureIO writes a temporary file into the mount, reads it back and stats
+// it, filling in row. The file is removed again in any case.
+func measureIO(mountPath string, data []byte, row *benchResult) error {
+	size := int64(benchSize) << 20
+	f, err := os.CreateTemp(mountPath, ".sshfs-connector-bench-*")
+	if err != nil {
+		return fmt.Errorf("cannot create test file: %v", err)
+	}
+	name := f.Name()
+	defer os.Remove(name)
+
+	start := time.Now()
+	for written := int64(0); written < size; written += int64(len(data)) {
+		chunk := data
+		if rest := size - written; rest < int64(len(chunk)) {
+			chunk = chunk[:rest]
+		}
+		if _, err := f.Write(chunk); err != nil {
+			f.Close()
+			return fmt.Errorf("write failed: %v", err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("write failed: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write failed: %v", err)
+	}
+	row.write = megabytesPerSecond(size, time.Since(start))
+
+	start = time.Now()
+	f, err = os.Open(name)
+	if err != nil {
+		return fmt.Errorf("read failed: %v", err)
+	}
+	read, err := io.Copy(io.Discard, f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("read failed: %v", err)
+	}
+	row.read = megabytesPerSecond(read, time.Since(start))
+
+	start = time.Now()
+	for i := 0; i < BENCH_STATS; i++ {
+		if _, err := os.Stat(name); err != nil {
+			return fmt.Errorf("stat failed: %v", err)
+		}
+	}
+	row.stat = time.Since(start) / BENCH_STATS
+	return nil
+}
+
+func megabytesPerSecond(bytes int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) / (1 << 20) / elapsed.Seconds()
+}
+
+func printBenchmark(rows []benchResult) {
+	fmt.Printf("%s%-24s %-22s %10s %11s %11s %10s%s\n", colorBold,
+		"HOST", "MOUNT PATH", "MOUNT TIME", "WRITE", "READ", "STAT", colorReset)
+	for _, row := range rows {
+		name := fmt.Sprintf("%s@%s", row.host.Username, row.host.IP)
+		if row.host.Label != "" {
+			name = row.host.Label
+		}
+		mountTime := "already"
+		if row.mountTime > 0 {
+			mountTime = fmt.Sprintf("%.3fs", row.mountTime.Seconds())
+		}
+		if row.err != nil && row.write == 0 {
+			fmt.Printf("%-24s %-22s %s%v%s\n", name, row.host.MountPath, colorRed, row.err, colorReset)
+			continue
+		}
+		fmt.Printf("%-24s %-22s %10s %7.1fMB/s %7.1fMB/s %8.1fms\n", name, row.host.MountPath, mountTime,
+			row.write, row.read, float64(row.stat.Microseconds())/1000)
+		if row.err != nil {
+			fmt.Printf("  %s%v%s\n", colorRed, row.err, colorReset)
+		}
+	}
+	fmt.Printf("%s%d MB written and read back per host; STAT is the average of %d stat calls%s\n",
+		colorDim, benchSize, BENCH_STATS, colorReset)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// BOOT_RETRY_INTERVAL is the time between the daemon's first cycles while
+// hosts are still unmounted, as when the network comes up after the daemon
+// at boot. At most BOOT_RETRY_CYCLES of these quick cycles run.
+const (
+	BOOT_RETRY_INTERVAL = 5 * time.Second
+	BOOT_RETRY_CYCLES   = 6
+)
+
+// bootRetry schedules the quick cycles after the daemon starts, on top of
+// the --check-interval ticker, which keeps its cadence. They stop after the
+// first cycle that ends with every host mounted, or once BOOT_RETRY_CYCLES
+// cycles have run. With a --check-interval no longer than
+// BOOT_RETRY_INTERVAL there are none.
+type bootRetry struct {
+	left  int              // cycles left on the quick schedule
+	timer <-chan time.Time // fires for the next quick cycle; nil when none
+}
+
+func newBootRetry() *bootRetry {
+	b := &bootRetry{}
+	if checkInterval > BOOT_RETRY_INTERVAL {
+		b.left = BOOT_RETRY_CYCLES
+		b.timer = time.After(BOOT_RETRY_INTERVAL)
+	}
+	return b
+}
+
+// after takes the results of every cycle, quick or not, and arms the next
+// quick cycle if hosts are still unmounted.
+func (b *bootRetry) after(results []sshfsmon.HostResult) {
+	b.timer = nil
+	if b.left == 0 {
+		return
+	}
+	unmounted := 0
+	for _, result := range results {
+		if !result.Mounted {
+			unmounted++
+		}
+	}
+	if unmounted == 0 {
+		b.left = 0
+		return
+	}
+	b.left--
+	if b.left == 0 {
+		logMessage(fmt.Sprintf("%d host(s) still unmounted after the quick startup cycles, checking every %s from now on", unmounted, checkInterval))
+		return
+	}
+	b.timer = time.After(BOOT_RETRY_INTERVAL)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// Circuit breaker states, as reported in a result's breaker field.
+const (
+	BREAKER_CLOSED    = "closed"    // probed every cycle
+	BREAKER_OPEN      = "open"      // skipped until its cooldown runs out
+	BREAKER_HALF_OPEN = "half-open" // cooldown over, the next cycle probes it
+)
+
+// BREAKER_MAX_SKIP is the default cap on the cycles an open breaker skips
+// between probes.
+const BREAKER_MAX_SKIP = 32
+
+// hostBreaker is the circuit breaker of a host that kept failing. While it
+// exists the breaker is open: the host sits out cycles, more of them after
+// every failed probe, until a probe mounts it again.
+type hostBreaker struct {
+	skip int // cycles skipped between probes, doubling up to --breaker-max-skip
+	wait int // cycles left to skip before the next probe
+}
+
+func (b *hostBreaker) state() string {
+	if b == nil {
+		return BREAKER_CLOSED
+	}
+	if b.wait == 0 {
+		return BREAKER_HALF_OPEN
+	}
+	return BREAKER_OPEN
+}
+
+// breakerHosts leaves out of hosts those whose breaker is open, counting
+// down their cooldown.
+func (s *daemonState) breakerHosts(hosts []sshfsmon.Host) []sshfsmon.Host {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.breakers) == 0 {
+		return hosts
+	}
+	var probe []sshfsmon.Host
+	for _, host := range hosts {
+		if b := s.breakers[host.MountPath]; b != nil && b.wait > 0 {
+			b.wait--
+			continue
+		}
+		probe = append(probe, host)
+	}
+	return probe
+}
+
+// updateBreakers opens the breaker of hosts that failed breakerAfter cycles
+// in a row, backs off those whose probe failed again, and closes those that
+// mounted. The caller holds mu.
+func (s *daemonState) updateBreakers(results []sshfsmon.HostResult) {
+	if breakerAfter <= 0 {
+		return
+	}
+	if s.breakers == nil {
+		s.breakers = make(map[string]*hostBreaker)
+	}
+	for _, result := range results {
+		path := result.Host.MountPath
+		b := s.breakers[path]
+		name := fmt.Sprintf("%s@%s", result.Host.Username, result.Host.IP)
+		switch {
+		case result.Mounted:
+			if b != nil {
+				delete(s.breakers, path)
+				logMessage(fmt.Sprintf("Circuit breaker for %s closed, %s is mounted again", name, path))
+			}
+		case b != nil:
+			b.skip *= 2
+			if b.skip > breakerMaxSkip {
+				b.skip = breakerMaxSkip
+			}
+			b.wait = b.skip
+			logMessage(fmt.Sprintf("Circuit breaker for %s stays open, next probe in %d cycles", name, b.skip+1))
+		case result.ConsecutiveFailures >= breakerAfter:
+			s.breakers[path] = &hostBreaker{skip: 1, wait: 1}
+			logMessage(fmt.Sprintf("Circuit breaker for %s opened after %d failed cycles, probing it every other cycle", name, result.ConsecutiveFailures))
+		}
+	}
+}
+
+// withSkipped adds the previous results of the hosts the breaker kept out
+// of this cycle to its results, in host order, and marks every result
+// with its breaker state. The caller holds mu.
+func (s *daemonState) withSkipped(results []sshfsmon.HostResult) []sshfsmon.HostResult {
+	if breakerAfter <= 0 {
+		return results
+	}
+	fresh := make(map[string]sshfsmon.HostResult)
+	for _, result := range results {
+		fresh[result.Host.MountPath] = result
+	}
+	previous := make(map[string]sshfsmon.HostResult)
+	for _, result := range s.results {
+		previous[result.Host.MountPath] = result
+	}
+
+	var merged []sshfsmon.HostResult
+	for _, host := range s.hosts {
+		result, ok := fresh[host.MountPath]
+		if !ok {
+			if s.breakers[host.MountPath] == nil {
+				continue
+			}
+			if result, ok = previous[host.MountPath]; !ok {
+				continue
+			}
+		}
+		result.Breaker = s.breakers[host.MountPath].state()
+		merged = append(merged, result)
+	}
+	return merged
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// Exit codes of check-all, following the monitoring plugin convention
+// (Nagios, Icinga).
+const (
+	CHECK_OK       = 0
+	CHECK_WARNING  = 1
+	CHECK_CRITICAL = 2
+	CHECK_UNKNOWN  = 3
+)
+
+// checkAllMode runs one cycle over all hosts and reports it as a monitoring
+// plugin: a one-line summary with perfdata, and OK when every host is
+// mounted and healthy, WARNING when a reachable host is not, CRITICAL when a
+// host is unreachable or a ro=true mount accepts writes. With --no-mount it
+// only looks, never mounts. --io-stats adds the traffic counters of the
+// sshfs processes to the perfdata.
+func checkAllMode() int {
+	hosts, err := loadHosts()
+	if err != nil {
+		fmt.Printf("SSHFS UNKNOWN - %v\n", err)
+		return CHECK_UNKNOWN
+	}
+
+	// One line of output only, and no remote info needed
+	monitor.Notice = nil
+	monitor.Config.RemoteInfo = false
+
+	if err := mountAccessError(hosts); err != nil && !noMount && !allowNonroot {
+		fmt.Printf("SSHFS UNKNOWN - %v\n", err)
+		return CHECK_UNKNOWN
+	}
+
+	var results []sshfsmon.HostResult
+	if noMount {
+		results = probeHosts(hosts)
+	} else {
+		results = monitor.ProcessHostsParallel(hosts)
+		monitor.WaitHooks()
+	}
+
+	var mounted, reachable, degraded, violations int
+	for _, result := range results {
+		if result.Mounted {
+			mounted++
+			if !result.HealthOK {
+				degraded++
+			}
+		}
+		if result.ReadOnlyViolation {
+			violations++
+		}
+		if result.Reachable {
+			reachable++
+		}
+	}
+
+	state, code := "OK", CHECK_OK
+	switch {
+	case reachable < len(results), violations > 0:
+		state, code = "CRITICAL", CHECK_CRITICAL
+	case mounted < len(results), degraded > 0:
+		state, code = "WARNING", CHECK_WARNING
+	}
+	var violated string
+	if violations > 0 {
+		violated = fmt.Sprintf(", %d READONLY-VIOLATION", violations)
+	}
+	// The sshfs processes' own counters; the grapher turns them into rates
+	var traffic string
+	if ioStats {
+		read, written := ioTotals(results)
+		traffic = fmt.Sprintf(" io_read=%dc io_written=%dc", read, written)
+	}
+	fmt.Printf("SSHFS %s - %d/%d mounted, %d/%d reachable, %d degraded%s | mounted=%d;;;0;%d reachable=%d;;;0;%d degraded=%d;;;0;%d total=%d%s\n",
+		state, mounted, len(results), reachable, len(results), degraded, violated,
+		mounted, len(results), reachable, len(results), degraded, len(results), len(results), traffic)
+	return code
+}
+
+// probeHosts checks every host's reachability and mount without changing
+// anything.
+func probeHosts(hosts []sshfsmon.Host) []sshfsmon.HostResult {
+	results := make([]sshfsmon.HostResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(index int, h sshfsmon.Host) {
+			defer wg.Done()
+			reachable, _, pingDuration := monitor.Ping(h)
+			results[index] = sshfsmon.HostResult{
+				Host:      h,
+				Reachable: reachable,
+				PingTime:  pingDuration,
+				Mounted:   reachable && sshfsmon.IsMountPoint(h.MountPath),
+			}
+			if results[index].Mounted {
+				monitor.CheckHealth(&results[index])
+			}
+		}(i, host)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// listenControl opens the daemon's control socket, readable and writable by
+// the owner only.
+func listenControl(path string) (net.Listener, error) {
+	// A leftover socket from a crashed daemon would make Listen fail. The PID
+	// check has already established that no other daemon owns it.
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	// Set the umask around Listen so the socket never exists with looser
+	// permissions, not even briefly.
+	oldMask := syscall.Umask(0177)
+	ln, err := net.Listen("unix", path)
+	syscall.Umask(oldMask)
+	if err != nil {
+		return nil, err
+	}
+	return ln, nil
+}
+
+func serveControl(ln net.Listener, state *daemonState) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			// The listener is closed on shutdown
+			return
+		}
+		go handleControlConn(conn, state)
+	}
+}
+
+// handleControlConn answers one line per command until the client hangs up:
+//
+//	status        latest cycle results as JSON
+//	reload        re-read the hosts file
+//	remount <ip>  mount the host now and return its results as JSON
+//	logs [n]      the daemon's last n log lines (default all kept) as JSON
+//	cycle         how long the last cycle took, against its deadline, as JSON
+func handleControlConn(conn net.Conn, state *daemonState) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var reply string
+		switch fields[0] {
+		case "status":
+			reply = controlJSON(state.latestResults())
+		case "reload":
+			if err := state.reload(); err != nil {
+				reply = fmt.Sprintf("ERROR %v", err)
+			} else {
+				reply = fmt.Sprintf("OK %d hosts loaded", len(state.currentHosts()))
+			}
+		case "remount":
+			if len(fields) != 2 {
+				reply = "ERROR usage: remount <ip>"
+				break
+			}
+			results, err := state.remount(fields[1])
+			if err != nil {
+				reply = fmt.Sprintf("ERROR %v", err)
+			} else {
+				reply = controlJSON(results)
+			}
+		case "logs":
+			n := 0
+			if len(fields) > 1 {
+				var err error
+				if n, err = strconv.Atoi(fields[1]); err != nil {
+					reply = "ERROR usage: logs [n]"
+					break
+				}
+			}
+			reply = controlJSON(recentLogs.tail(n))
+		case "cycle":
+			reply = controlJSON(state.cycleStats())
+		default:
+			reply = fmt.Sprintf("ERROR unknown command %q (want status, reload, remount <ip>, logs [n] or cycle)", fields[0])
+		}
+
+		if _, err := fmt.Fprintln(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// recentLogLines asks the daemon listening on path for its last n log lines.
+func recentLogLines(path string, n int) ([]string, error) {
+	var lines []string
+	if err := controlQuery(path, fmt.Sprintf("logs %d", n), &lines); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// controlQuery sends one command to the daemon listening on path and
+// decodes its JSON reply into v. An ERROR reply is returned as an error.
+func controlQuery(path, command string, v interface{}) error {
+	conn, err := net.DialTimeout("unix", path, time.Duration(config.Timeout)*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Duration(config.Timeout) * time.Second))
+
+	if _, err := fmt.Fprintln(conn, command); err != nil {
+		return err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(reply, "ERROR ") {
+		return fmt.Errorf("%s", strings.TrimSpace(strings.TrimPrefix(reply, "ERROR ")))
+	}
+	return json.Unmarshal([]byte(reply), v)
+}
+
+func controlJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("ERROR %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+)
+
+var ansiSequence = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// displayWidth returns how many terminal columns s takes: ANSI escape
+// sequences take none, combining marks none, and East Asian wide characters
+// two.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range ansiSequence.ReplaceAllString(s, "") {
+		switch {
+		case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Me, r), unicode.Is(unicode.Cf, r):
+		case isWide(r):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// isWide reports East Asian wide and fullwidth characters, and emoji.
+func isWide(r rune) bool {
+	return r >= 0x1100 && (r <= 0x115f || // Hangul Jamo
+		r == 0x2329 || r == 0x232a ||
+		(r >= 0x2e80 && r <= 0xa4cf && r != 0x303f) || // CJK ... Yi
+		(r >= 0xac00 && r <= 0xd7a3) || // Hangul syllables
+		(r >= 0xf900 && r <= 0xfaff) || // CJK compatibility ideographs
+		(r >= 0xfe30 && r <= 0xfe4f) || // CJK compatibility forms
+		(r >= 0xff00 && r <= 0xff60) || // fullwidth forms
+		(r >= 0xffe0 && r <= 0xffe6) ||
+		(r >= 0x1f300 && r <= 0x1f64f) || // pictographs, emoticons
+		(r >= 0x1f680 && r <= 0x1f6ff) || // transport and map symbols
+		(r >= 0x1f900 && r <= 0x1f9ff) ||
+		(r >= 0x20000 && r <= 0x3fffd))
+}
+
+// padRight pads s with spaces to width columns. Text already as wide or
+// wider is returned as is.
+func padRight(s string, width int) string {
+	if pad := width - displayWidth(s); pad > 0 {
+		return s + strings.Repeat(" ", pad)
+	}
+	return s
+}
+
+// truncate cuts s to width columns, ending it with an ellipsis if anything
+// was cut. Escape sequences are kept, and if there were any the color is
+// reset at the end, so a cut inside a badge does not bleed into the next
+// line.
+func truncate(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	used, escaped := 0, false
+	for s != "" {
+		if s[0] == '\x1b' {
+			if loc := ansiSequence.FindStringIndex(s); loc != nil && loc[0] == 0 {
+				b.WriteString(s[:loc[1]])
+				s = s[loc[1]:]
+				escaped = true
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		w := displayWidth(string(r))
+		if used+w > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+		s = s[size:]
+	}
+	b.WriteString("…")
+	if escaped {
+		b.WriteString(colorReset)
+	}
+	return b.String()
+}
+
+// DASHBOARD_WIDTH is the inner width of the dashboard's header box when the
+// terminal size is unknown, as when stdout is not a terminal.
+const DASHBOARD_WIDTH = 62
+
+// MIN_DASHBOARD_WIDTH keeps the boxes usable on very narrow terminals.
+const MIN_DASHBOARD_WIDTH = 30
+
+// terminalWidth returns the columns of the terminal on stdout, or 0 if
+// stdout is not a terminal.
+func terminalWidth() int {
+	var size struct{ rows, cols, xpixel, ypixel uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&size)))
+	if errno != 0 {
+		return 0
+	}
+	return int(size.cols)
+}
+
+// dashboardLayout sizes the dashboard to the terminal: the inner width of
+// the header box, the width host lines are cut to (0 leaves them whole) and
+// the cells of the usage bars. Without a terminal it is the fixed layout.
+func dashboardLayout() (inner, lineWidth, usageCells int) {
+	columns := terminalWidth()
+	if columns <= 0 {
+		return DASHBOARD_WIDTH, 0, USAGE_BAR
+	}
+	// The summary box is one column wider than the header box
+	inner = columns - 3
+	if inner < MIN_DASHBOARD_WIDTH {
+		inner = MIN_DASHBOARD_WIDTH
+	}
+	usageCells = columns / 8
+	if usageCells < 5 {
+		usageCells = 5
+	} else if usageCells > 20 {
+		usageCells = 20
+	}
+	return inner, columns, usageCells
+}
+
+// lastFrame holds the lines on screen after the previous drawFrame.
+var lastFrame []string
+
+// clearScreen wipes the screen and forgets the last frame, so the next
+// drawFrame redraws everything, as after a resize left the old lines
+// rewrapped at the wrong width.
+func clearScreen() {
+	fmt.Print("\033[H\033[2J")
+	lastFrame = nil
+}
+
+// drawFrame puts a rendered dashboard on the screen. By default the screen
+// is cleared and redrawn. With --no-clear-screen the cursor is sent home and
+// only the lines that differ from the previous frame are rewritten, which
+// avoids flicker and saves bandwidth on remote terminals.
+func drawFrame(frame string) {
+	if !noClearScreen {
+		fmt.Print("\033[?25l\033[H\033[2J" + frame + "\033[?25h")
+		return
+	}
+
+	lines := strings.Split(strings.TrimSuffix(frame, "\n"), "\n")
+	var out strings.Builder
+	out.WriteString("\033[?25l")
+	for i, line := range lines {
+		if i < len(lastFrame) && lastFrame[i] == line {
+			continue
+		}
+		// Move to the line, write it, erase what is left of the old one
+		fmt.Fprintf(&out, "\033[%d;1H%s\033[K", i+1, line)
+	}
+	// Erase anything below a frame that got shorter
+	fmt.Fprintf(&out, "\033[%d;1H\033[J\033[?25h", len(lines)+1)
+	os.Stdout.WriteString(out.String())
+	lastFrame = lines
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{"ascii", "mounted", 7},
+		{"empty", "", 0},
+		{"colour escapes", "\033[32mOK\033[0m", 2},
+		{"bold colour", "\033[1;31mFAILED\033[0m", 6},
+		{"multi-byte latin", "café", 4},
+		{"combining mark", "café", 4},
+		{"cjk", "服务器", 6},
+		{"hangul", "서버", 4},
+		{"emoji", "🚀 up", 5},
+		{"fullwidth", "ＡＢ", 4},
+		{"coloured cjk", "\033[33m备份\033[0m", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := displayWidth(tt.s); got != tt.want {
+				t.Errorf("displayWidth(%q) = %d, want %d", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPadRightSameVisibleWidth(t *testing.T) {
+	const width = 12
+	cells := []string{
+		"plain",
+		"\033[32mMOUNTED\033[0m",
+		"\033[1;31mFAILED\033[0m",
+		"café",
+		"café",
+		"服务器",
+		"\033[33m备份\033[0m",
+		"🚀 up",
+		"",
+	}
+	for _, cell := range cells {
+		padded := padRight(cell, width)
+		if got := displayWidth(padded); got != width {
+			t.Errorf("padRight(%q) is %d columns wide, want %d", cell, got, width)
+		}
+	}
+
+	// Too wide already: left alone
+	long := "\033[32mVERY-LONG-STATUS\033[0m"
+	if got := padRight(long, width); got != long {
+		t.Errorf("padRight changed an over-wide cell: %q", got)
+	}
+}
+
+func TestStatusBadgesAlign(t *testing.T) {
+	want := displayWidth(statusBadge("OFFLINE"))
+	for status := range statusBadges {
+		if got := displayWidth(statusBadge(status)); got != want {
+			t.Errorf("%s badge is %d columns wide, OFFLINE is %d", status, got, want)
+		}
+	}
+	if got := displayWidth(statusBadge("UNKNOWN")); got != want {
+		t.Errorf("unknown status badge is %d columns wide, OFFLINE is %d", got, want)
+	}
+}
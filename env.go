@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ENV_PREFIX starts the environment variables that stand in for flags, for
+// containers where passing flags or placing files is awkward: --check-interval
+// is SSHFS_CHECK_INTERVAL. A flag on the command line wins over the
+// environment, which wins over the built-in default.
+const ENV_PREFIX = "SSHFS_"
+
+// envNames are the variables of flags whose derived name would be unclear
+// or clash with the SSHFS_* variables hooks are run with.
+var envNames = map[string]string{
+	"hosts": "SSHFS_HOSTS_FILE",
+	"user":  "SSHFS_UNIT_USER",
+	"group": "SSHFS_UNIT_GROUP",
+}
+
+// secretFlags hold URLs that may carry credentials, redacted in the logged
+// configuration.
+var secretFlags = map[string]bool{
+	"hosts":   true,
+	"webhook": true,
+}
+
+// hiddenFlags are secrets themselves, never logged.
+var hiddenFlags = map[string]bool{
+	"http-auth-token": true,
+}
+
+// flagSources records where the flags set by something other than their
+// default came from: "flag", or the environment variable's name.
+var flagSources = make(map[string]string)
+
+// parsedFlags is the parsed flag set, for logging the effective
+// configuration.
+var parsedFlags *flag.FlagSet
+
+func envName(flagName string) string {
+	if name, ok := envNames[flagName]; ok {
+		return name
+	}
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets the flags not given on the command line from their
+// environment variables.
+func applyEnv(fs *flag.FlagSet) error {
+	parsedFlags = fs
+	fs.Visit(func(f *flag.Flag) {
+		flagSources[f.Name] = "flag"
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || flagSources[f.Name] != "" {
+			return
+		}
+		name := envName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid %s=%q: %v", name, value, setErr)
+			return
+		}
+		flagSources[f.Name] = name
+	})
+	return err
+}
+
+// ALWAYS_LOGGED are the settings the effective configuration always shows,
+// set or not.
+var ALWAYS_LOGGED = []string{"hosts", "log-file", "pid-file", "check-interval", "mount-base"}
+
+// effectiveConfig describes the settings the daemon runs with: the main
+// paths and every setting changed from its default, with where it came from.
+func effectiveConfig() string {
+	if parsedFlags == nil {
+		return ""
+	}
+	shown := make(map[string]bool)
+	for _, name := range ALWAYS_LOGGED {
+		shown[name] = true
+	}
+
+	var settings []string
+	parsedFlags.VisitAll(func(f *flag.Flag) {
+		source := flagSources[f.Name]
+		if !shown[f.Name] && source == "" {
+			return
+		}
+		value := f.Value.String()
+		if secretFlags[f.Name] {
+			value = redactURL(value)
+		}
+		if hiddenFlags[f.Name] && value != "" {
+			value = "REDACTED"
+		}
+		setting := fmt.Sprintf("%s=%s", f.Name, value)
+		if source != "" && source != "flag" {
+			setting += " (" + source + ")"
+		}
+		settings = append(settings, setting)
+	})
+	return strings.Join(settings, " ")
+}
+
+// redactURL hides the credentials a URL may carry, user info and query; any
+// other value is returned as is.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return value
+	}
+	if u.User != nil {
+		u.User = url.User("REDACTED")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "REDACTED"
+	}
+	return u.String()
+}
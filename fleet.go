@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// Fleet states, of all hosts together, for --on-all-up and --on-all-down.
+// They double as the SSHFS_EVENT of the hooks.
+const (
+	FLEET_ALL_UP   = "all-up"   // every host mounted
+	FLEET_ALL_DOWN = "all-down" // every host unreachable
+	FLEET_MIXED    = "mixed"
+)
+
+func fleetState(results []sshfsmon.HostResult) string {
+	mounted, unreachable := 0, 0
+	for _, result := range results {
+		if result.Mounted {
+			mounted++
+		}
+		if !result.Reachable {
+			unreachable++
+		}
+	}
+	switch {
+	case mounted == len(results):
+		return FLEET_ALL_UP
+	case unreachable == len(results):
+		return FLEET_ALL_DOWN
+	}
+	return FLEET_MIXED
+}
+
+// fleetTransition records the fleet state of a cycle's results, all hosts
+// included, and returns the state if the fleet just became all up or all
+// down, else "". The first cycle only establishes the state. The caller
+// holds mu.
+func (s *daemonState) fleetTransition(results []sshfsmon.HostResult) string {
+	if len(results) == 0 {
+		return ""
+	}
+	previous := s.fleet
+	s.fleet = fleetState(results)
+	if previous == "" || s.fleet == previous || s.fleet == FLEET_MIXED {
+		return ""
+	}
+	return s.fleet
+}
+
+// runFleetHook logs a fleet transition and starts its hook, if one is set.
+// SSHFS_HOST_COUNT tells the hook how many hosts there are.
+func runFleetHook(state string, hosts int) {
+	command := onAllUp
+	message := fmt.Sprintf("All %d hosts mounted", hosts)
+	if state == FLEET_ALL_DOWN {
+		command = onAllDown
+		message = fmt.Sprintf("ERROR: All %d hosts unreachable", hosts)
+	}
+	logMessage(message)
+	monitor.RunFleetHook(command, state, []string{"SSHFS_HOST_COUNT=" + strconv.Itoa(hosts)})
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// HISTORY_FILE is where the daemon appends a sample per host per cycle, one
+// tab separated line each: unix time, mount path, reachable, mounted and the
+// ping time in milliseconds ("-" when there was no answer).
+const HISTORY_FILE = "/var/lib/sshfs-monitor/history.tsv"
+
+const (
+	HISTORY_RETENTION      = 7 * 24 * time.Hour
+	HISTORY_PRUNE_INTERVAL = time.Hour // prune old samples at most this often
+	HISTORY_QUEUE          = 16        // cycles waiting to be written
+	HISTORY_COLUMNS        = 60        // sparkline width
+)
+
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+type historySample struct {
+	time      time.Time
+	mountPath string
+	reachable bool
+	mounted   bool
+	ping      float64 // milliseconds, NaN without an answer
+}
+
+func (s historySample) String() string {
+	ping := "-"
+	if !math.IsNaN(s.ping) {
+		ping = strconv.FormatFloat(s.ping, 'f', 1, 64)
+	}
+	return fmt.Sprintf("%d\t%s\t%s\t%s\t%s", s.time.Unix(), s.mountPath, boolDigit(s.reachable), boolDigit(s.mounted), ping)
+}
+
+func boolDigit(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func parseHistorySample(line string) (historySample, bool) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 5 {
+		return historySample{}, false
+	}
+	unix, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return historySample{}, false
+	}
+	sample := historySample{
+		time:      time.Unix(unix, 0),
+		mountPath: fields[1],
+		reachable: fields[2] == "1",
+		mounted:   fields[3] == "1",
+		ping:      math.NaN(),
+	}
+	if ping, err := strconv.ParseFloat(fields[4], 64); err == nil {
+		sample.ping = ping
+	}
+	return sample, true
+}
+
+// historyWriter appends the daemon's cycle results to the history file. The
+// monitor loop only queues them; a full queue drops the cycle rather than
+// holding up monitoring behind a slow disk.
+type historyWriter struct {
+	path      string
+	retention time.Duration
+	queue     chan []historySample
+	lastPrune time.Time
+}
+
+func newHistoryWriter(path string, retention time.Duration) *historyWriter {
+	return &historyWriter{
+		path:      path,
+		retention: retention,
+		queue:     make(chan []historySample, HISTORY_QUEUE),
+	}
+}
+
+func (w *historyWriter) record(results []sshfsmon.HostResult) {
+	now := time.Now()
+	samples := make([]historySample, 0, len(results))
+	for _, result := range results {
+		sample := historySample{
+			time:      now,
+			mountPath: result.Host.MountPath,
+			reachable: result.Reachable,
+			mounted:   result.Mounted,
+			ping:      math.NaN(),
+		}
+		if result.Reachable && result.PingTime > 0 {
+			sample.ping = float64(result.PingTime) / float64(time.Millisecond)
+		}
+		samples = append(samples, sample)
+	}
+
+	select {
+	case w.queue <- samples:
+	default:
+		logMessage("History writer is falling behind, dropping this cycle's samples")
+	}
+}
+
+func (w *historyWriter) run() {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		logMessage(fmt.Sprintf("History disabled: %v", err))
+		for range w.queue {
+		}
+		return
+	}
+	for samples := range w.queue {
+		if err := w.append(samples); err != nil {
+			logMessage(fmt.Sprintf("Failed to write history: %v", err))
+		}
+		if w.retention > 0 && time.Since(w.lastPrune) >= HISTORY_PRUNE_INTERVAL {
+			if err := w.prune(); err != nil {
+				logMessage(fmt.Sprintf("Failed to prune history: %v", err))
+			}
+			w.lastPrune = time.Now()
+		}
+	}
+}
+
+func (w *historyWriter) append(samples []historySample) error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, sample := range samples {
+		b.WriteString(sample.String())
+		b.WriteByte('\n')
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// prune rewrites the history file without the samples older than the
+// retention window, replacing it atomically so readers never see half a file.
+func (w *historyWriter) prune() error {
+	cutoff := time.Now().Add(-w.retention).Unix()
+	in, err := os.Open(w.path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := w.path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	dropped := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		unix, _, _ := strings.Cut(line, "\t")
+		if t, err := strconv.ParseInt(unix, 10, 64); err != nil || t < cutoff {
+			dropped++
+			continue
+		}
+		writer.WriteString(line)
+		writer.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if dropped == 0 {
+		return os.Remove(tmp)
+	}
+	return os.Rename(tmp, w.path)
+}
+
+// readHistory returns the samples of the given mount paths since start, in
+// the order they were written.
+func readHistory(path string, mountPaths map[string]bool, start time.Time) ([]historySample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []historySample
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		sample, ok := parseHistorySample(scanner.Text())
+		if ok && mountPaths[sample.mountPath] && !sample.time.Before(start) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples, scanner.Err()
+}
+
+// historyMode prints a host's reachability over the --since window: uptime
+// percentages and sparklines of availability and ping time. The host is
+// looked up in the hosts file like mount and check, or given as its mount
+// path.
+func historyMode(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: ./sshfs-connector history <ip|user@ip|mount_path>")
+		return EXIT_USAGE
+	}
+
+	var hosts []sshfsmon.Host
+	if filepath.IsAbs(args[0]) {
+		hosts = []sshfsmon.Host{{MountPath: filepath.Clean(args[0])}}
+	} else {
+		var err error
+		if hosts, err = findHosts(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return EXIT_UNKNOWN_HOST
+		}
+	}
+
+	now := time.Now()
+	start := now.Add(-historySince)
+	mountPaths := make(map[string]bool)
+	for _, host := range hosts {
+		mountPaths[host.MountPath] = true
+	}
+	samples, err := readHistory(historyFile, mountPaths, start)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		return EXIT_MOUNT_FAILED
+	}
+
+	exitCode := EXIT_MOUNTED
+	for _, host := range hosts {
+		var own []historySample
+		for _, sample := range samples {
+			if sample.mountPath == host.MountPath {
+				own = append(own, sample)
+			}
+		}
+		name := host.MountPath
+		if host.IP != "" {
+			name = fmt.Sprintf("%s@%s -> %s", host.Username, host.IP, host.MountPath)
+		}
+		if len(own) == 0 {
+			fmt.Printf("%s: no history in the last %s\n", name, historySince)
+			exitCode = EXIT_MOUNT_FAILED
+			continue
+		}
+		printHistory(name, own, start, now)
+	}
+	return exitCode
+}
+
+func printHistory(name string, samples []historySample, start, end time.Time) {
+	reachable, mounted, pinged := 0, 0, 0
+	var pingTotal, pingMax float64
+	for _, sample := range samples {
+		if sample.reachable {
+			reachable++
+		}
+		if sample.mounted {
+			mounted++
+		}
+		if !math.IsNaN(sample.ping) {
+			pinged++
+			pingTotal += sample.ping
+			pingMax = math.Max(pingMax, sample.ping)
+		}
+	}
+
+	// Bucket the samples by time: availability is the mounted share of a
+	// bucket, ping its average
+	up := make([]float64, HISTORY_COLUMNS)
+	ping := make([]float64, HISTORY_COLUMNS)
+	count := make([]int, HISTORY_COLUMNS)
+	pings := make([]int, HISTORY_COLUMNS)
+	window := end.Sub(start)
+	for _, sample := range samples {
+		i := int(float64(sample.time.Sub(start)) / float64(window) * HISTORY_COLUMNS)
+		if i < 0 || i >= HISTORY_COLUMNS {
+			i = HISTORY_COLUMNS - 1
+		}
+		count[i]++
+		if sample.mounted {
+			up[i]++
+		}
+		if !math.IsNaN(sample.ping) {
+			pings[i]++
+			ping[i] += sample.ping
+		}
+	}
+	for i := range up {
+		if count[i] > 0 {
+			up[i] /= float64(count[i])
+		} else {
+			up[i] = math.NaN()
+		}
+		if pings[i] > 0 {
+			ping[i] /= float64(pings[i]) * pingMax
+		} else {
+			ping[i] = math.NaN()
+		}
+	}
+
+	total := float64(len(samples))
+	fmt.Printf("%s%s%s\n", colorBold, name, colorReset)
+	fmt.Printf("  Reachable %.1f%%  Mounted %.1f%%  (%d samples)\n", float64(reachable)/total*100, float64(mounted)/total*100, len(samples))
+	fmt.Printf("  Mounted |%s|\n", sparkline(up))
+	if pinged > 0 {
+		fmt.Printf("  Ping    |%s| avg %.1fms, max %.1fms\n", sparkline(ping), pingTotal/float64(pinged), pingMax)
+	}
+	from, to := start.Format("Jan 2 15:04"), end.Format("Jan 2 15:04")
+	fmt.Printf("  %s%9s%-*s%s%s\n", colorDim, "", HISTORY_COLUMNS+1-len(to), from, to, colorReset)
+}
+
+// sparkline draws values between 0 and 1 as block characters; NaN, a stretch
+// without samples, is left blank.
+func sparkline(values []float64) string {
+	var b strings.Builder
+	for _, v := range values {
+		if math.IsNaN(v) {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(sparkLevels[int(math.Round(v*float64(len(sparkLevels)-1)))])
+	}
+	return b.String()
+}
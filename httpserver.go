@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// httpListenAddr binds an address without a host, such as ":6060", to
+// localhost: what the daemon serves over HTTP exposes enough of it not to
+// serve it to the network unless asked for explicitly.
+func httpListenAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
+
+// httpURL is the URL the server startHTTPServer makes for addr serves path
+// on, for the log.
+func httpURL(addr, path string) string {
+	scheme := "http"
+	if httpTLSCert != "" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, httpListenAddr(addr), path)
+}
+
+// startHTTPServer is how the daemon serves anything over HTTP: bound to
+// localhost unless addr names a host, over HTTPS with --http-tls-cert and
+// --http-tls-key, and with --http-auth-token only to requests carrying
+// that bearer token. Close the server to stop it.
+func startHTTPServer(addr string, handler http.Handler) (*http.Server, error) {
+	var certs []tls.Certificate
+	if httpTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(httpTLSCert, httpTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading --http-tls-cert and --http-tls-key: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if httpAuthToken != "" {
+		handler = requireToken(httpAuthToken, handler)
+	}
+
+	ln, err := net.Listen("tcp", httpListenAddr(addr))
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: handler}
+	if certs != nil {
+		server.TLSConfig = &tls.Config{Certificates: certs, MinVersion: tls.VersionTLS12}
+		ln = tls.NewListener(ln, server.TLSConfig)
+	}
+	go server.Serve(ln)
+	return server, nil
+}
+
+// requireToken passes on only requests with an "Authorization: Bearer
+// <token>" header, comparing in constant time so response times do not
+// give the token away.
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
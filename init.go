@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// initMode writes a commented sample hosts file to --hosts, documenting
+// every token the hosts file takes, so a first run has something to edit
+// instead of an error. An existing file is only replaced with --force.
+func initMode() int {
+	if hostsSource == "-" || strings.HasPrefix(hostsSource, "http://") || strings.HasPrefix(hostsSource, "https://") {
+		fmt.Fprintf(os.Stderr, "Error: init writes a local file; --hosts %s is not one\n", hostsSource)
+		return EXIT_USAGE
+	}
+	if format := monitor.HostsFormatFor(hostsSource); format != "fields" {
+		fmt.Fprintf(os.Stderr, "Error: init writes the fields format, but %s would be read as %s\n", hostsSource, format)
+		return EXIT_USAGE
+	}
+	if _, err := os.Stat(hostsSource); err == nil && !forceInit {
+		fmt.Fprintf(os.Stderr, "Error: %s already exists; pass --force to overwrite it\n", hostsSource)
+		return EXIT_USAGE
+	}
+
+	if err := os.WriteFile(hostsSource, []byte(sshfsmon.SampleHosts()), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return EXIT_MOUNT_FAILED
+	}
+	path, err := filepath.Abs(hostsSource)
+	if err != nil {
+		path = hostsSource
+	}
+	fmt.Printf("Wrote sample hosts file %s\n", path)
+	return EXIT_MOUNTED
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// ioCounters are the I/O counters of the sshfs process serving a mount:
+// rchar and wchar from /proc/<pid>/io, all bytes it read and wrote, on the
+// ssh side and the FUSE side alike.
+type ioCounters struct {
+	pid           int
+	read, written uint64
+}
+
+// sshfsCounters returns the I/O counters of the sshfs process serving
+// mountPath, and false if there is none or /proc is unavailable.
+func sshfsCounters(mountPath string) (ioCounters, bool) {
+	for _, pid := range monitor.SSHFSProcesses(mountPath) {
+		data, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "io"))
+		if err != nil {
+			return ioCounters{}, false
+		}
+		counters := ioCounters{pid: pid}
+		for _, line := range strings.Split(string(data), "\n") {
+			key, value, _ := strings.Cut(line, ":")
+			n, _ := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+			switch key {
+			case "rchar":
+				counters.read = n
+			case "wchar":
+				counters.written = n
+			}
+		}
+		return counters, true
+	}
+	return ioCounters{}, false
+}
+
+// showTraffic puts each mount's traffic next to its usage bar, in watch
+// with --io-stats. A single dashboard has nothing to add up.
+var showTraffic bool
+
+// ioAccounting is --io-stats: the bytes each mount's sshfs process read and
+// wrote, added up cycle by cycle.
+type ioAccounting struct {
+	mounts map[string]*mountIO // keyed by mount path
+}
+
+type mountIO struct {
+	last          ioCounters // at the previous sample; pid 0 when there was no process
+	read, written uint64     // totals so far
+}
+
+func newIOAccounting() *ioAccounting {
+	return &ioAccounting{mounts: make(map[string]*mountIO)}
+}
+
+// sample reads the counters of every mounted host, adds how much they grew
+// since the last sample to the host's totals, and puts the totals into the
+// results. The first sample of a mount only takes its baseline. A process
+// with a new PID, after a remount, starts over from zero, so its traffic
+// since the remount is counted in full.
+func (a *ioAccounting) sample(results []sshfsmon.HostResult) {
+	for i := range results {
+		path := results[i].Host.MountPath
+		account, seen := a.mounts[path]
+		if !seen {
+			account = &mountIO{}
+			a.mounts[path] = account
+		}
+		counters, ok := sshfsCounters(path)
+		switch {
+		case !results[i].Mounted || !ok:
+			counters = ioCounters{}
+		case !seen:
+		default:
+			base := account.last
+			if base.pid != counters.pid || base.read > counters.read || base.written > counters.written {
+				base = ioCounters{}
+			}
+			account.read += counters.read - base.read
+			account.written += counters.written - base.written
+		}
+		account.last = counters
+		results[i].IORead, results[i].IOWritten = account.read, account.written
+	}
+}
+
+// ioTotals sums the current counters of the sshfs processes of the mounted
+// hosts, for check-all's perfdata.
+func ioTotals(results []sshfsmon.HostResult) (read, written uint64) {
+	for _, result := range results {
+		if !result.Mounted {
+			continue
+		}
+		if counters, ok := sshfsCounters(result.Host.MountPath); ok {
+			read += counters.read
+			written += counters.written
+		}
+	}
+	return read, written
+}
+
+// formatBytes shortens a byte count for the dashboard, as 12MB.
+func formatBytes(n uint64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, unit := float64(n)/1024, 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if value < 10 {
+		return fmt.Sprintf("%.1f%cB", value, units[unit])
+	}
+	return fmt.Sprintf("%.0f%cB", value, units[unit])
+}
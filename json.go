@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// daemonStatus is what status --json prints. Only Running is set when no
+// daemon runs; Hosts needs the daemon's --control-socket.
+type daemonStatus struct {
+	Running       bool    `json:"running"`
+	PID           int     `json:"pid,omitempty"`
+	PIDFile       string  `json:"pid_file,omitempty"`
+	LogFile       string  `json:"log_file,omitempty"`
+	Syslog        bool    `json:"syslog,omitempty"`
+	CheckInterval string  `json:"check_interval,omitempty"`
+	StartedAt     string  `json:"started_at,omitempty"`
+	UptimeSeconds float64 `json:"uptime_seconds,omitempty"`
+
+	// Hosts are the results of the daemon's latest cycle, or HostsError
+	// tells why they could not be had from the control socket.
+	Hosts      []sshfsmon.HostResult `json:"hosts,omitempty"`
+	HostsError string                `json:"hosts_error,omitempty"`
+
+	// LastCycle is the daemon's last cycle, also from the control socket
+	LastCycle *cycleStats `json:"last_cycle,omitempty"`
+}
+
+// statusJSONMode is status --json: the daemon's status as one JSON object
+// on stdout, {"running":false} when it does not run. Like status it returns
+// non-zero in that case.
+func statusJSONMode() int {
+	status := runningDaemon()
+	if status.Running && controlSocket != "" {
+		var results []sshfsmon.HostResult
+		if err := controlQuery(controlSocket, "status", &results); err != nil {
+			status.HostsError = err.Error()
+		} else {
+			status.Hosts = results
+		}
+		var cycle cycleStats
+		if err := controlQuery(controlSocket, "cycle", &cycle); err == nil && cycle.FinishedAt != "" {
+			status.LastCycle = &cycle
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(status)
+	if !status.Running {
+		return 1
+	}
+	return 0
+}
+
+// runningDaemon looks for the daemon through the PID file. The daemon
+// writes the file when it starts, so its modification time is the start
+// time. A PID file without a live process is removed, as status does.
+func runningDaemon() daemonStatus {
+	daemon, err := checkDaemon(osPidFS{}, pidPath)
+	if err != nil || daemon.State != daemonRunning {
+		return daemonStatus{}
+	}
+	info, pid := daemon.Info, daemon.PID
+
+	status := daemonStatus{
+		Running:       true,
+		PID:           pid,
+		PIDFile:       pidPath,
+		Syslog:        useSyslog,
+		CheckInterval: checkInterval.String(),
+		StartedAt:     info.ModTime().Format(time.RFC3339),
+		UptimeSeconds: time.Since(info.ModTime()).Round(time.Second).Seconds(),
+	}
+	if !syslogOnly() {
+		status.LogFile = logPath
+	}
+	return status
+}
+
+// hostJSON is one host in once --json: the status once's table would show,
+// or reachStatus for once --check-only, and the full result, its error
+// included.
+type hostJSON struct {
+	Status string              `json:"status"`
+	Result sshfsmon.HostResult `json:"result"`
+}
+
+// printResultsJSON is once --json: the results as one JSON array on stdout,
+// in hosts file order, for scripts and pipelines.
+func printResultsJSON(results []sshfsmon.HostResult, status func(sshfsmon.HostResult) string) {
+	hosts := make([]hostJSON, len(results))
+	for i, result := range results {
+		hosts[i] = hostJSON{Status: status(result), Result: result}
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(hosts)
+}
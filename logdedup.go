@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// LOG_REPEAT_INTERVAL is how often a suppressed repeating log line is
+// summarized by default.
+const LOG_REPEAT_INTERVAL = 10 * time.Minute
+
+// hostLogger keeps a permanently failing host from writing the same line to
+// the log every cycle. A line repeated for the same host is written once,
+// then summarized every repeatInterval, and a "recovered" line is written
+// when it stops. Lines are keyed by host and message, so hosts never
+// suppress each other.
+type hostLogger struct {
+	mu             sync.Mutex
+	repeatInterval time.Duration
+	entries        map[string]*hostLogEntry
+}
+
+type hostLogEntry struct {
+	host     sshfsmon.Host
+	message  string
+	count    int       // cycles in a row the line was logged
+	lastSeen time.Time // last time the line was logged
+	written  time.Time // last time it reached the log file
+}
+
+func newHostLogger(repeatInterval time.Duration) *hostLogger {
+	return &hostLogger{
+		repeatInterval: repeatInterval,
+		entries:        make(map[string]*hostLogEntry),
+	}
+}
+
+func (l *hostLogger) log(host sshfsmon.Host, message string) {
+	if l.repeatInterval <= 0 {
+		logMessage(message)
+		return
+	}
+
+	key := host.Username + "@" + host.IP + " " + host.MountPath + "\x00" + message
+	now := time.Now()
+	l.mu.Lock()
+	entry, seen := l.entries[key]
+	if !seen {
+		l.entries[key] = &hostLogEntry{host: host, message: message, count: 1, lastSeen: now, written: now}
+		l.mu.Unlock()
+		logMessage(message)
+		return
+	}
+	entry.count++
+	entry.lastSeen = now
+	summarize := now.Sub(entry.written) >= l.repeatInterval
+	if summarize {
+		entry.written = now
+	}
+	count := entry.count
+	l.mu.Unlock()
+
+	if summarize {
+		logMessage(fmt.Sprintf("%s (still occurring, %d cycles)", message, count))
+	}
+}
+
+// sweep ends the repeats of lines not logged since cycleStart, writing a
+// recovered line for those that had been repeating.
+func (l *hostLogger) sweep(cycleStart time.Time) {
+	var recovered []string
+	l.mu.Lock()
+	for key, entry := range l.entries {
+		if !entry.lastSeen.Before(cycleStart) {
+			continue
+		}
+		if entry.count > 1 {
+			recovered = append(recovered, fmt.Sprintf("Recovered: %s@%s no longer reports %q (after %d cycles)",
+				entry.host.Username, entry.host.IP, entry.message, entry.count))
+		}
+		delete(l.entries, key)
+	}
+	l.mu.Unlock()
+
+	for _, line := range recovered {
+		logMessage(line)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync"
+)
+
+// LOG_BUFFER is the default number of log lines the daemon keeps in memory.
+const LOG_BUFFER = 200
+
+// logRing keeps the most recent log lines for the control socket, so they can
+// be read without the log file. logMessage is called from the parallel mount
+// goroutines, hence the lock.
+type logRing struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+func newLogRing(size int) *logRing {
+	return &logRing{lines: make([]string, size)}
+}
+
+func (r *logRing) add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.lines) == 0 {
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// tail returns up to n of the newest lines, oldest first. n <= 0 returns all
+// of them.
+func (r *logRing) tail(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lines []string
+	if r.full {
+		lines = append(lines, r.lines[r.next:]...)
+	}
+	lines = append(lines, r.lines[:r.next]...)
+	if n > 0 && n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
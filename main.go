@@ -1,58 +1,46 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
-)
-
-type Host struct {
-	IP        string
-	MountPath string
-	Port      int
-	RemoteDir string
-	Username  string
-}
-
-type HostResult struct {
-	Host          Host
-	Reachable     bool
-	PingTime      time.Duration
-	CheckTime     time.Duration
-	Mounted       bool
-	MountTime     time.Duration
-	ExecutedCmd   string
-	Error         error
-	RemoteInfo    RemoteInfo
-}
 
-type RemoteInfo struct {
-	Hostname string
-	Uptime   string
-	MAC      string
-}
+	"sshfs-connector/sshfsmon"
+)
 
 const (
 	HOSTS_FILE     = "./sshfs_hosts.txt"
-	MOUNT_BASE     = "/root"
-	MOUNT_OPTIONS  = "cache=no,attr_timeout=0,entry_timeout=0"
-	TIMEOUT        = 3
 	CHECK_INTERVAL = 30
 	LOG_FILE       = "/var/log/sshfs-monitor.log"
 	PID_FILE       = "/var/run/sshfs-monitor.pid"
 )
 
+// Exit codes of the single-host mount and check commands.
+const (
+	EXIT_MOUNTED      = 0
+	EXIT_MOUNT_FAILED = 1
+	EXIT_UNREACHABLE  = 2
+	EXIT_UNKNOWN_HOST = 3
+	EXIT_USAGE        = 64
+)
+
+// EXIT_THRESHOLD is the exit code of once when --fail-on is not met.
+const EXIT_THRESHOLD = 10
+
 var (
 	daemonMode   = false
 	logFile      *os.File
@@ -73,206 +61,591 @@ var (
 	bgCyan       = "\033[46m"
 )
 
-func initLogging() error {
-	var err error
-	logFile, err = os.OpenFile(LOG_FILE, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+// Command-line options shared by all subcommands, set by parseFlags. The
+// options that shape mounting live in config.
+var (
+	config           = sshfsmon.DefaultConfig()
+	hostsSource      = HOSTS_FILE
+	controlSocket    string
+	failureThreshold int
+	webhookURL       string
+	unitUser         string
+	unitGroup        string
+	fixFuseConf      bool
+	watchMounts      bool
+	failOn           string
+	logBufferSize    int
+	logRepeatEvery   time.Duration
+	quiet            bool
+	idleTimeout      time.Duration
+	remountAfter     int
+	staleAfter       int
+	watchOutput      string
+	noDF             bool
+	noMount          bool
+	escalateAfter    int
+	cycleDeadline    time.Duration
+	breakerAfter     int
+	breakerMaxSkip   int
+	noClearScreen    bool
+	historyFile      string
+	historyRetention time.Duration
+	historySince     time.Duration
+	benchSize        int
+	benchUnmount     bool
+	forceInit        bool
+	jsonOutput       bool
+	summaryOnly      bool
+	checkOnly        bool
+	ioStats          bool
+	allowNonroot     bool
+	foreground       bool
+	onAllUp          string
+	onAllDown        string
+	pprofAddr        string
+	httpTLSCert      string
+	httpTLSKey       string
+	httpAuthToken    string
+	pruneDirs        bool
+	tagFilter        []string
+	tagMatch         string
+	useSyslog        bool
+	syslogFacility   string
+	logPath          = LOG_FILE
+	pidPath          = PID_FILE
+	registryPath     = MOUNT_REGISTRY
+	checkInterval    = CHECK_INTERVAL * time.Second
+)
+
+// remoteFieldLabels name the remote info fields in the dashboard.
+var remoteFieldLabels = map[string]string{
+	"hostname": "Host",
+	"uptime":   "Up",
+	"mac":      "MAC",
+	"loadavg":  "Load",
+	"diskfree": "Free /",
+}
+
+// screenMode is set while watch or dashboard draw the screen.
+var (
+	screenMode    bool
+	screenNotices noticeQueue
+)
+
+// hostLog deduplicates the monitor's per-host log lines in daemon mode.
+var hostLog *hostLogger
+
+// recentLogs holds the daemon's latest log lines, served over the control
+// socket. It is nil outside the daemon.
+var recentLogs *logRing
+
+// monitor does the mounting, configured from config once the flags are parsed.
+var monitor *sshfsmon.Monitor
+
+func parseFlags(command string, args []string) []string {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.StringVar(&hostsSource, "hosts", HOSTS_FILE, "hosts list: a file, - for stdin, or an http(s) URL")
+	fs.StringVar(&logPath, "log-file", LOG_FILE, "daemon log file")
+	fs.BoolVar(&useSyslog, "syslog", false, "log to syslog instead of the log file, or as well if --log-file is given")
+	fs.StringVar(&syslogFacility, "syslog-facility", "daemon", "syslog facility: daemon, user or local0 to local7")
+	fs.StringVar(&pidPath, "pid-file", PID_FILE, "daemon PID file")
+	fs.StringVar(&registryPath, "mount-registry", MOUNT_REGISTRY, "file recording which daemon manages which mount paths")
+	fs.DurationVar(&checkInterval, "check-interval", CHECK_INTERVAL*time.Second, "time between the daemon's monitoring cycles")
+	fs.StringVar(&config.ControlDir, "control-dir", sshfsmon.CONTROL_DIR, "directory for the --controlmaster sockets")
+	fs.StringVar(&config.HostsFormat, "hosts-format", "auto", "hosts list format: fields, csv (user@ip,mount_path,port,remote_dir,label with a header row), toml ([[hosts]] tables), or auto to go by the .csv/.toml extension")
+	fs.StringVar(&controlSocket, "control-socket", "", "Unix socket for daemon control commands (start only)")
+	fs.IntVar(&failureThreshold, "failure-threshold", 10, "consecutive failed cycles before a host is logged as ERROR (0 disables)")
+	fs.StringVar(&webhookURL, "webhook", "", "URL to POST a JSON alert to when a host reaches the failure threshold")
+	fs.StringVar(&config.MountBase, "mount-base", sshfsmon.MOUNT_BASE, "directory relative mount paths are resolved against")
+	fs.Var(fileModeFlag{&config.MountPerm}, "mount-perm", "octal mode for mount directories the tool creates")
+	fs.StringVar(&config.SSHFSPath, "sshfs-path", "sshfs", "sshfs binary to mount with, looked up in PATH")
+	fs.StringVar(&config.RclonePath, "rclone-path", "rclone", "rclone binary for backend=rclone hosts, looked up in PATH")
+	fs.Var(repeatedListFlag{&config.SSHFSOptions}, "sshfs-opt", "sshfs -o option for every host, below its opts=; repeat for several")
+	fs.Var(repeatedListFlag{&config.SSHFSOptions}, "o", "short for --sshfs-opt")
+	fs.Var(argsFlag{&config.SSHFSExtraArgs}, "sshfs-extra-args", "extra arguments for every sshfs command, e.g. \"-o idmap=user\"")
+	fs.StringVar(&config.SSHPath, "ssh-path", "ssh", "ssh binary for the remote info probes, looked up in PATH")
+	fs.StringVar(&config.StrictHostKey, "strict-host-key", "no", "ssh StrictHostKeyChecking for mounts and probes: no, yes or accept-new")
+	fs.StringVar(&config.KnownHostsFile, "known-hosts", "", "ssh UserKnownHostsFile for hosts without their own known_hosts= option")
+	fs.StringVar(&config.SSHConfig, "ssh-config", "", "ssh config file passed with -F to sshfs and ssh, for alias=true hosts")
+	fs.IntVar(&config.PingCount, "ping-count", sshfsmon.PING_COUNT, "probes per reachability check; one answer is enough (1 for speed)")
+	fs.StringVar(&config.PingMethod, "ping-method", "icmp", "reachability check: icmp (ping) or tcp (connect to the SSH port)")
+	fs.IntVar(&config.SSHConnectTimeout, "ssh-connect-timeout", sshfsmon.SSH_CONNECT_TIMEOUT, "ssh ConnectTimeout in seconds for mounts and remote info probes")
+	fs.Var(idFlag{&config.UID, sshfsmon.LookupUID}, "uid", "local owner of mounted files, a uid or user name (per host: uid=)")
+	fs.Var(idFlag{&config.GID, sshfsmon.LookupGID}, "gid", "local group of mounted files, a gid or group name (per host: gid=)")
+	fs.BoolVar(&config.AllowOther, "allow-other", false, "mount with allow_other so other local users can access the mounts")
+	fs.StringVar(&config.MountAs, "mount-as", "", "run sshfs and unmounts as this local user through sudo (needs --allow-other)")
+	fs.BoolVar(&fixFuseConf, "fix-fuse-conf", false, "add user_allow_other to /etc/fuse.conf when --allow-other needs it")
+	fs.BoolVar(&config.ControlMaster, "controlmaster", false, "share one ssh connection per host between the mount and the remote info probes")
+	fs.BoolVar(&config.RemoteInfo, "remote-info", true, "probe mounted hosts over ssh for the --remote-fields (=false where only sftp is allowed)")
+	fs.Var(repeatedListFlag{&tagFilter}, "tag", "only act on hosts with this tags= tag; repeat or comma-separate for several")
+	fs.StringVar(&tagMatch, "tag-match", "any", "with several --tag: any (hosts with one of them) or all (hosts with every one)")
+	fs.Var(listFlag{&config.RemoteFields}, "remote-fields", "remote info fields to probe and show, in order: hostname, uptime, mac, loadavg, diskfree")
+	fs.BoolVar(&config.ParallelRemoteInfo, "parallel-remote-info", true, "run the remote info ssh probes of a host concurrently (=false for strict sshd limits)")
+	fs.DurationVar(&config.MountTimeout, "mount-timeout", sshfsmon.MOUNT_TIMEOUT*time.Second, "kill an sshfs that has not mounted after this long (0 waits forever)")
+	fs.DurationVar(&config.MountTimeWarn, "max-mount-time-warn", 0, "log and highlight mounts that succeed but take longer than this (0 disables)")
+	fs.DurationVar(&config.MountGrace, "mount-grace", 0, "never take a mount this young as stale when it is slow to answer (start only, 0 disables)")
+	fs.DurationVar(&config.RemountCooldown, "remount-cooldown", 0, "leave a stale mount in place for this long after mounting it (0 disables)")
+	fs.IntVar(&config.MaxPerDestination, "max-per-destination", 0, "mount at most N hosts behind the same IP at once, e.g. a bastion (0 for no limit)")
+	fs.StringVar(&config.PostMountHook, "post-mount-hook", "", "command run in the background after a host is newly mounted, with SSHFS_* variables describing it")
+	fs.StringVar(&config.PostUnmountHook, "post-unmount-hook", "", "command run in the background after a mount is torn down, with SSHFS_* variables describing it")
+	fs.StringVar(&onAllUp, "on-all-up", "", "command the daemon runs when all hosts have become mounted, not on the first cycle (start only)")
+	fs.StringVar(&onAllDown, "on-all-down", "", "command the daemon runs when all hosts have become unreachable, not on the first cycle (start only)")
+	fs.DurationVar(&config.HealthCheckTimeout, "healthcheck-timeout", sshfsmon.HEALTHCHECK_TIMEOUT, "fail a host's healthcheck= command that runs longer than this (0 waits forever)")
+	fs.DurationVar(&config.HookTimeout, "hook-timeout", sshfsmon.HOOK_TIMEOUT, "kill a hook that runs longer than this (0 waits forever)")
+	fs.BoolVar(&config.Debug, "debug", false, "log the ssh error of failed remote info probes (start only)")
+	fs.BoolVar(&config.Conservative, "conservative", false, "never unmount: report stale mounts instead of clearing them")
+	fs.BoolVar(&config.DeepCheck, "deep-check", false, "prove mounts end to end by writing and reading back a sentinel file through them")
+	fs.BoolVar(&config.AllowShadow, "allow-shadow", false, "mount over mount directories that have files in them, hiding the files while mounted")
+	fs.BoolVar(&config.SafeRemount, "safe-remount", false, "clear a stale mount only once ssh to its host works, keeping it while a remount could not succeed")
+	fs.BoolVar(&config.DelayConnect, "delay-connect", false, "mount with sshfs delay_connect,reconnect, also hosts not reachable yet, which connect on first use")
+	fs.IntVar(&remountAfter, "remount-after", 1, "cycles a host must be reachable in a row before it is mounted (start only)")
+	fs.IntVar(&staleAfter, "stale-after", 2, "cycles a host must fail in a row before its stale mount is torn down (start only)")
+	fs.DurationVar(&cycleDeadline, "cycle-deadline", 0, "stop waiting for a cycle's mounts after this long (start only; default 80% of --check-interval)")
+	fs.IntVar(&escalateAfter, "escalate-after", 3, "cycles a mount may stay hung before its sshfs is killed and it is remounted, doubling after each try (start only, 0 disables)")
+	fs.IntVar(&breakerAfter, "breaker-after", 0, "failed cycles in a row after which a host is only probed now and then, backing off (start only, 0 disables)")
+	fs.IntVar(&breakerMaxSkip, "breaker-max-skip", BREAKER_MAX_SKIP, "most cycles an open circuit breaker skips between probes (start only)")
+	fs.DurationVar(&idleTimeout, "idle-timeout", 0, "unmount mounts unused for this long; with --watch-mounts they come back on access (start only, 0 disables)")
+	fs.StringVar(&pprofAddr, "pprof-addr", "", "serve net/http/pprof profiles of the daemon on this address, localhost unless a host is given (start only)")
+	fs.StringVar(&httpTLSCert, "http-tls-cert", "", "serve the daemon's HTTP endpoints over HTTPS with this PEM certificate (needs --http-tls-key)")
+	fs.StringVar(&httpTLSKey, "http-tls-key", "", "PEM private key for --http-tls-cert")
+	fs.StringVar(&httpAuthToken, "http-auth-token", "", "require \"Authorization: Bearer TOKEN\" on every request to the daemon's HTTP endpoints")
+	fs.BoolVar(&watchMounts, "watch-mounts", false, "remount as soon as inotify reports a mount going away, between cycles (start only)")
+	fs.StringVar(&failOn, "fail-on", "0", "once exits 10 if fewer than this percentage of hosts mounted, or any host failed with \"any\"")
+	fs.IntVar(&logBufferSize, "log-buffer", LOG_BUFFER, "log lines the daemon keeps in memory for status (start only)")
+	fs.DurationVar(&logRepeatEvery, "log-repeat-interval", LOG_REPEAT_INTERVAL, "summarize a host's repeated log line this often instead of every cycle (0 logs every repeat)")
+	fs.StringVar(&watchOutput, "output", "tui", "watch output: tui (dashboard) or jsonl (one JSON object per host per refresh)")
+	fs.StringVar(&historyFile, "history-file", HISTORY_FILE, "file the daemon appends per-host samples to each cycle, read by history (empty disables)")
+	fs.DurationVar(&historyRetention, "history-retention", HISTORY_RETENTION, "drop history samples older than this (start only, 0 keeps everything)")
+	fs.DurationVar(&historySince, "since", 24*time.Hour, "history: how far back to show")
+	fs.IntVar(&benchSize, "bench-size", BENCH_SIZE, "benchmark: MB written and read back per host")
+	fs.BoolVar(&benchUnmount, "bench-unmount", false, "benchmark: unmount the hosts it mounted once measured")
+	fs.BoolVar(&forceInit, "force", false, "init: overwrite an existing hosts file")
+	fs.BoolVar(&foreground, "foreground", false, "start: run under a supervisor, logging to stdout without a PID file")
+	fs.BoolVar(&allowNonroot, "allow-nonroot", false, "only warn, instead of stopping, when a non-root user cannot write the mount paths")
+	fs.BoolVar(&ioStats, "io-stats", false, "count the bytes each mount's sshfs process reads and writes (start, watch, check-all)")
+	fs.BoolVar(&jsonOutput, "json", false, "status and once: print JSON instead of text, for scripts")
+	fs.BoolVar(&checkOnly, "check-only", false, "once: only check reachability, as the reach command, without mounting anything")
+	fs.BoolVar(&summaryOnly, "summary-only", false, "once: print only the summary and total time, for cron")
+	fs.BoolVar(&pruneDirs, "prune", false, "prune: remove the orphaned mount directories instead of listing them; start: remove them whenever the hosts are loaded")
+	fs.BoolVar(&noClearScreen, "no-clear-screen", false, "watch and dashboard: redraw in place, rewriting only changed lines, instead of clearing the screen")
+	fs.BoolVar(&noDF, "no-df", false, "skip disk usage in once, watch and dashboard for the fastest refresh")
+	fs.BoolVar(&noMount, "no-mount", false, "check-all: only check reachability and mounts, never mount")
+	fs.BoolVar(&quiet, "quiet", false, "suppress banners and stale endpoint notices in once, watch and dashboard")
+	fs.StringVar(&unitUser, "user", "", "User= for the generated systemd unit (export-systemd)")
+	fs.StringVar(&unitGroup, "group", "", "Group= for the generated systemd unit (export-systemd)")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(EXIT_USAGE)
+	}
+	if err := applyEnv(fs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(EXIT_USAGE)
+	}
+	if err := validateFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(EXIT_USAGE)
+	}
+	// An explicit mount base must be usable; the default is left alone so
+	// hosts with absolute paths keep working for non-root users
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "mount-base" {
+			if err := checkMountBase(config.MountBase); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(EXIT_USAGE)
+			}
+		}
+	})
+	return fs.Args()
+}
+
+// argsFlag splits a flag value into whitespace-separated arguments.
+type argsFlag struct {
+	args *[]string
+}
+
+func (f argsFlag) String() string {
+	if f.args == nil {
+		return ""
+	}
+	return strings.Join(*f.args, " ")
+}
+
+func (f argsFlag) Set(value string) error {
+	*f.args = strings.Fields(value)
+	return nil
+}
+
+// idFlag parses --uid or --gid, given as a number or a local user or group
+// name, into the numeric id.
+type idFlag struct {
+	id     *string
+	lookup func(string) (string, error)
+}
+
+func (f idFlag) String() string {
+	if f.id == nil {
+		return ""
+	}
+	return *f.id
+}
+
+func (f idFlag) Set(value string) error {
+	id, err := f.lookup(value)
 	if err != nil {
-		return fmt.Errorf("failed to open log file: %v", err)
+		return err
 	}
-	log.SetOutput(logFile)
+	*f.id = id
 	return nil
 }
 
-func logMessage(message string) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	logEntry := fmt.Sprintf("[%s] %s", timestamp, message)
-	if daemonMode && logFile != nil {
-		log.Println(message)
+// listFlag parses a comma-separated list flag.
+type listFlag struct {
+	list *[]string
+}
+
+func (f listFlag) String() string {
+	if f.list == nil {
+		return ""
 	}
-	if !daemonMode {
-		fmt.Println(logEntry)
+	return strings.Join(*f.list, ",")
+}
+
+func (f listFlag) Set(value string) error {
+	*f.list = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f.list = append(*f.list, item)
+		}
 	}
+	return nil
 }
 
-func loadHosts() ([]Host, error) {
-	file, err := os.Open(HOSTS_FILE)
+// fileModeFlag parses an octal permission flag such as 750.
+type fileModeFlag struct {
+	mode *os.FileMode
+}
+
+func (f fileModeFlag) String() string {
+	if f.mode == nil {
+		return ""
+	}
+	return fmt.Sprintf("%o", uint32(*f.mode))
+}
+
+func (f fileModeFlag) Set(value string) error {
+	mode, err := strconv.ParseUint(value, 8, 32)
 	if err != nil {
-		return nil, fmt.Errorf("error opening hosts file %s: %v", HOSTS_FILE, err)
+		return fmt.Errorf("not an octal mode: %s", value)
 	}
-	defer file.Close()
+	*f.mode = os.FileMode(mode)
+	return nil
+}
 
-	var hosts []Host
-	scanner := bufio.NewScanner(file)
-	
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
+// checkMountBase makes sure base is a directory the tool can create mount
+// points in.
+func checkMountBase(base string) error {
+	info, err := os.Stat(base)
+	if err != nil {
+		return fmt.Errorf("--mount-base: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("--mount-base %s is not a directory", base)
+	}
+	if err := syscall.Access(base, 2); err != nil { // W_OK
+		return fmt.Errorf("--mount-base %s is not writable: %v", base, err)
+	}
+	return nil
+}
 
-		parts := strings.Fields(line)
-		if len(parts) < 2 {
-			continue
+func validateFlags() error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if checkInterval <= 0 {
+		return fmt.Errorf("--check-interval must be positive, got %s", checkInterval)
+	}
+	if remountAfter < 1 {
+		return fmt.Errorf("--remount-after must be at least 1, got %d", remountAfter)
+	}
+	if cycleDeadline < 0 {
+		return fmt.Errorf("--cycle-deadline must not be negative, got %s", cycleDeadline)
+	}
+	if escalateAfter < 0 {
+		return fmt.Errorf("--escalate-after must not be negative, got %d", escalateAfter)
+	}
+	if breakerAfter < 0 {
+		return fmt.Errorf("--breaker-after must not be negative, got %d", breakerAfter)
+	}
+	if breakerMaxSkip < 1 {
+		return fmt.Errorf("--breaker-max-skip must be at least 1, got %d", breakerMaxSkip)
+	}
+	if historyRetention < 0 {
+		return fmt.Errorf("--history-retention must not be negative, got %s", historyRetention)
+	}
+	if historySince <= 0 {
+		return fmt.Errorf("--since must be positive, got %s", historySince)
+	}
+	if _, ok := syslogFacilities[syslogFacility]; !ok {
+		return fmt.Errorf("--syslog-facility must be daemon, user or local0 to local7, got %q", syslogFacility)
+	}
+	if tagMatch != "any" && tagMatch != "all" {
+		return fmt.Errorf("--tag-match must be any or all, got %q", tagMatch)
+	}
+	if benchSize < 1 {
+		return fmt.Errorf("--bench-size must be at least 1, got %d", benchSize)
+	}
+	if staleAfter < 1 {
+		return fmt.Errorf("--stale-after must be at least 1, got %d", staleAfter)
+	}
+	if (httpTLSCert == "") != (httpTLSKey == "") {
+		return fmt.Errorf("--http-tls-cert and --http-tls-key must be given together")
+	}
+	if watchOutput != "tui" && watchOutput != "jsonl" {
+		return fmt.Errorf("--output must be tui or jsonl, got %q", watchOutput)
+	}
+	if failOn != "any" {
+		if percent, err := strconv.Atoi(failOn); err != nil || percent < 0 || percent > 100 {
+			return fmt.Errorf("--fail-on must be a percentage from 0 to 100 or \"any\", got %q", failOn)
 		}
+	}
+	return nil
+}
 
-		// Extract username and host
-		var username, hostIP string
-		if strings.Contains(parts[0], "@") {
-			splitHost := strings.SplitN(parts[0], "@", 2)
-			username = splitHost[0]
-			hostIP = splitHost[1]
-		} else {
-			username = "root"
-			hostIP = parts[0]
+// newMonitor builds the monitor from config, sending its events to the log
+// in daemon mode. Stale endpoint notices are printed in the other modes too.
+func newMonitor() *sshfsmon.Monitor {
+	m := sshfsmon.New(config)
+	m.Log = func(host sshfsmon.Host, message string) {
+		if daemonMode {
+			hostLog.log(host, message)
 		}
-
-		host := Host{
-			IP:        hostIP,
-			MountPath: parts[1],
-			Port:      22,
-			RemoteDir: "/root",
-			Username:  username,
+	}
+	m.Notice = func(message string) {
+		switch {
+		case daemonMode:
+			logMessage(message)
+		case watchOutput == "jsonl", jsonOutput:
+			// Keep stdout machine-readable
+			fmt.Fprintln(os.Stderr, message)
+		case quiet:
+		case screenMode:
+			screenNotices.add(message)
+		default:
+			fmt.Println(message)
 		}
+	}
+	return m
+}
 
-		// Handle mount path
-		if !filepath.IsAbs(host.MountPath) {
-			host.MountPath = filepath.Join(MOUNT_BASE, host.MountPath)
-		}
+// noticeQueue holds notices raised while watch or dashboard own the screen.
+// They are shown under the status box on the next redraw rather than printed
+// into the middle of it.
+type noticeQueue struct {
+	mu       sync.Mutex
+	messages []string
+}
 
-		// Handle port
-		if len(parts) > 2 {
-			if port, err := strconv.Atoi(parts[2]); err == nil {
-				host.Port = port
-			}
-		}
+func (q *noticeQueue) add(message string) {
+	q.mu.Lock()
+	q.messages = append(q.messages, message)
+	q.mu.Unlock()
+}
 
-		// Handle remote directory
-		if len(parts) > 3 {
-			host.RemoteDir = parts[3]
-		}
+func (q *noticeQueue) take() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	messages := q.messages
+	q.messages = nil
+	return messages
+}
 
-		hosts = append(hosts, host)
-	}
+// HOSTS_EXAMPLE is the hosts file line suggested when there is no hosts file.
+const HOSTS_EXAMPLE = "192.168.1.100 sshfs"
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading hosts file: %v", err)
+// loadHosts reads the hosts list from the --hosts source, keeping the hosts
+// --tag selects.
+func loadHosts() ([]sshfsmon.Host, error) {
+	hosts, err := loadAllHosts()
+	if err != nil {
+		return nil, err
 	}
+	return selectTagged(hosts)
+}
 
-	if len(hosts) == 0 {
-		return nil, fmt.Errorf("no hosts found in %s", HOSTS_FILE)
+// loadAllHosts loads every host of the hosts source, whatever --tag says.
+func loadAllHosts() ([]sshfsmon.Host, error) {
+	if hostsFileMissing() {
+		return nil, fmt.Errorf("hosts file %s not found; create it with one host per line, e.g. %q, or run init for a commented sample", hostsSource, HOSTS_EXAMPLE)
 	}
+	return monitor.LoadHosts(hostsSource)
+}
 
-	return hosts, nil
+// hostsFileMissing reports whether the hosts source is a local file that
+// does not exist, as on a first run, rather than one that fails to parse.
+func hostsFileMissing() bool {
+	if hostsSource == "-" || strings.HasPrefix(hostsSource, "http://") || strings.HasPrefix(hostsSource, "https://") {
+		return false
+	}
+	_, err := os.Stat(hostsSource)
+	return os.IsNotExist(err)
 }
 
-func pingHost(host string, timeout int) (bool, time.Duration) {
-	start := time.Now()
-	cmd := exec.Command("ping", "-c", "1", "-W", strconv.Itoa(timeout), host)
-	err := cmd.Run()
-	duration := time.Since(start)
-	return err == nil, duration
+// noHostsReason explains an empty watch or dashboard screen: why the hosts
+// could not be loaded.
+var noHostsReason string
+
+// loadScreenHosts loads the hosts for watch and dashboard. Instead of exiting
+// on an error it records it for the screen and returns no hosts.
+func loadScreenHosts() []sshfsmon.Host {
+	hosts, err := loadHosts()
+	if err != nil {
+		noHostsReason = err.Error()
+		return nil
+	}
+	noHostsReason = ""
+	return hosts
 }
 
-func getPingTime(host string) string {
-	cmd := exec.Command("ping", "-c", "1", "-W", strconv.Itoa(TIMEOUT), host)
-	output, err := cmd.Output()
+// hostsReloadError is why the last watch refresh could not reload the hosts
+// and shows the ones loaded before instead.
+var hostsReloadError string
+
+// reloadScreenHosts rereads the hosts on a watch refresh. Once there are
+// hosts, a failed reload, as when the hosts file was deleted, keeps them on
+// screen under an error banner until a reload succeeds again.
+func reloadScreenHosts(previous []sshfsmon.Host) []sshfsmon.Host {
+	if len(previous) == 0 {
+		return loadScreenHosts()
+	}
+	// Stdin is read once, there is nothing to reload from
+	if hostsSource == "-" {
+		return previous
+	}
+	hosts, err := loadHosts()
 	if err != nil {
-		return "N/A"
+		hostsReloadError = err.Error()
+		return previous
+	}
+	hostsReloadError = ""
+	return hosts
+}
+
+// initLogging opens the daemon's log file, or with --syslog connects to
+// syslog, falling back to the file when syslog cannot be reached.
+func initLogging() error {
+	var syslogErr error
+	if useSyslog {
+		sysLog, syslogErr = openSyslog()
+		if syslogErr == nil && syslogOnly() {
+			return nil
+		}
 	}
 	
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "time=") {
-			parts := strings.Split(line, "time=")
-			if len(parts) > 1 {
-				timeStr := strings.Fields(parts[1])[0]
-				return timeStr
-			}
+	if foreground {
+		// The supervisor collects stdout
+		log.SetOutput(os.Stdout)
+		if syslogErr != nil {
+			log.Printf("WARN: syslog unavailable, logging here instead: %v", syslogErr)
 		}
+		return nil
+	}
+	
+	var err error
+	logFile, err = os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
 	}
-	return "N/A"
+	log.SetOutput(logFile)
+	if syslogErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: syslog unavailable, logging to %s instead: %v\n", logPath, syslogErr)
+		log.Printf("WARN: syslog unavailable, logging here instead: %v", syslogErr)
+	}
+	return nil
 }
 
-func clearStaleEndpoint(mountPoint string) error {
-	// Check if directory exists
-	if _, err := os.Stat(mountPoint); os.IsNotExist(err) {
+// reopenLog replaces the log file with a fresh one at --log-file, for
+// logrotate's postrotate: once the old file is renamed away, writes would
+// otherwise keep going to it. The new file is opened before the old one is
+// closed, so a failed open keeps logging where it was.
+func reopenLog() error {
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logFile == nil {
 		return nil
 	}
-
-	// Try to access the directory
-	cmd := exec.Command("ls", mountPoint)
-	if err := cmd.Run(); err != nil {
-		if !daemonMode {
-			fmt.Printf("Detected stale SSHFS endpoint at %s, clearing...\n", mountPoint)
-		} else {
-			logMessage(fmt.Sprintf("Detected stale SSHFS endpoint at %s, clearing...", mountPoint))
-		}
-		
-		// Try fusermount first
-		cmd = exec.Command("fusermount", "-u", mountPoint)
-		if err := cmd.Run(); err != nil {
-			// Try umount
-			cmd = exec.Command("umount", mountPoint)
-			if err := cmd.Run(); err != nil {
-				// Try lazy umount
-				cmd = exec.Command("umount", "-l", mountPoint)
-				cmd.Run()
-			}
-		}
-		
-		time.Sleep(time.Second)
-		
-		// Verify cleanup
-		cmd = exec.Command("ls", mountPoint)
-		if err := cmd.Run(); err == nil {
-			if !daemonMode {
-				fmt.Printf("Successfully cleared stale endpoint: %s\n", mountPoint)
-			} else {
-				logMessage(fmt.Sprintf("Successfully cleared stale endpoint: %s", mountPoint))
-			}
-		} else {
-			if !daemonMode {
-				fmt.Printf("Warning: Could not fully clear stale endpoint: %s\n", mountPoint)
-			} else {
-				logMessage(fmt.Sprintf("Warning: Could not fully clear stale endpoint: %s", mountPoint))
-			}
-		}
+	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen log file: %v", err)
 	}
-	
+	log.SetOutput(file)
+	logFile.Close()
+	logFile = file
 	return nil
 }
 
-func getRemoteInfo(host Host, infoType string) string {
-	// Check if mounted first
-	cmd := exec.Command("mountpoint", "-q", host.MountPath)
-	if err := cmd.Run(); err != nil {
-		return "N/A"
+// logMu serializes logMessage, which the monitor loop, the control socket,
+// the mount watcher and the history writer all call, so each line lands in
+// the log file, the ring and stdout in the same order. It also guards
+// logFile against reopenLog.
+var logMu sync.Mutex
+
+func logMessage(message string) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	logEntry := fmt.Sprintf("[%s] %s", timestamp, message)
+	if daemonMode && sysLog != nil {
+		writeSyslog(sysLog, message)
+	}
+	if daemonMode && (logFile != nil || foreground) {
+		log.Println(message)
+	}
+	if recentLogs != nil {
+		recentLogs.add(logEntry)
 	}
+	if !daemonMode {
+		fmt.Println(logEntry)
+	}
+}
 
-	var sshCmd string
-	switch infoType {
-	case "uptime":
-		sshCmd = "uptime | sed 's/.*up \\([^,]*\\).*/\\1/' | xargs"
-	case "hostname":
-		sshCmd = "hostname"
-	case "mac":
-		sshCmd = "cat /sys/class/net/eth0/address 2>/dev/null || ip link show eth0 2>/dev/null | grep -o '[0-9a-f][0-9a-f]:[0-9a-f][0-9a-f]:[0-9a-f][0-9a-f]:[0-9a-f][0-9a-f]:[0-9a-f][0-9a-f]:[0-9a-f][0-9a-f]' | head -1"
-	default:
-		return "N/A"
+// checkFuseConf warns when allow_other is requested but /etc/fuse.conf does
+// not permit it for non-root users, and adds the setting if asked to.
+func checkFuseConf() {
+	const fuseConf = "/etc/fuse.conf"
+	data, err := ioutil.ReadFile(fuseConf)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Warning: cannot read %s: %v\n", fuseConf, err)
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "user_allow_other" {
+			return
+		}
 	}
 
-	cmd = exec.Command("ssh", "-p", strconv.Itoa(host.Port), "-o", "ConnectTimeout=2", 
-		"-o", "StrictHostKeyChecking=no", fmt.Sprintf("%s@%s", host.Username, host.IP), sshCmd)
-	output, err := cmd.Output()
-	if err != nil {
-		return "N/A"
+	if !fixFuseConf {
+		fmt.Fprintf(os.Stderr, "Warning: allow_other requested but user_allow_other is not set in %s; mounts by non-root users will fail (use --fix-fuse-conf to add it)\n", fuseConf)
+		return
 	}
-	
-	result := strings.TrimSpace(string(output))
-	if result == "" {
-		return "N/A"
+	f, err := os.OpenFile(fuseConf, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err == nil {
+		if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+			_, err = f.WriteString("\n")
+		}
+		if err == nil {
+			_, err = f.WriteString("user_allow_other\n")
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not add user_allow_other to %s: %v\n", fuseConf, err)
+		return
 	}
-	return result
+	fmt.Fprintf(os.Stderr, "Added user_allow_other to %s\n", fuseConf)
 }
 
 func getLocalInfo(infoType string) string {
@@ -295,171 +668,126 @@ func getLocalInfo(infoType string) string {
 	return strings.TrimSpace(string(output))
 }
 
-func mountHost(host Host) HostResult {
-	start := time.Now()
-	
-	result := HostResult{
-		Host: host,
-	}
-
-	// Check if host is reachable
-	reachable, pingDuration := pingHost(host.IP, TIMEOUT)
-	result.Reachable = reachable
-	result.PingTime = pingDuration
-	result.CheckTime = time.Since(start)
-
-	if !reachable {
-		if daemonMode {
-			logMessage(fmt.Sprintf("Host %s not reachable", host.IP))
-		}
-		return result
-	}
-
-	pingTimeStr := getPingTime(host.IP)
-	if daemonMode {
-		logMessage(fmt.Sprintf("Host %s reachable (ping: %s)", host.IP, pingTimeStr))
-	}
-
-	// Clear stale endpoints
-	clearStaleEndpoint(host.MountPath)
-
-	// Create mount directory if it doesn't exist
-	if err := os.MkdirAll(host.MountPath, 0755); err != nil {
-		result.Error = fmt.Errorf("failed to create mount directory: %v", err)
-		return result
-	}
-
-	// Check if already mounted
-	cmd := exec.Command("mountpoint", "-q", host.MountPath)
-	if err := cmd.Run(); err == nil {
-		// Verify mount is accessible
-		cmd = exec.Command("ls", host.MountPath)
-		if err := cmd.Run(); err == nil {
-			result.Mounted = true
-			result.ExecutedCmd = "already_mounted"
-			if daemonMode {
-				logMessage(fmt.Sprintf("Mount verified: %s", host.MountPath))
-			}
-			// Get remote info
-			result.RemoteInfo = RemoteInfo{
-				Hostname: getRemoteInfo(host, "hostname"),
-				Uptime:   getRemoteInfo(host, "uptime"),
-				MAC:      getRemoteInfo(host, "mac"),
-			}
-			return result
-		}
-		// Stale mount, clean it
-		clearStaleEndpoint(host.MountPath)
-	}
-
-	// Mount the filesystem
-	mountStart := time.Now()
-	sshfsCmd := fmt.Sprintf("sshfs %s@%s:%s/ %s -o %s,port=%d",
-		host.Username, host.IP, host.RemoteDir, host.MountPath, MOUNT_OPTIONS, host.Port)
-	
-	result.ExecutedCmd = sshfsCmd
-	
-	cmd = exec.Command("sshfs", 
-		fmt.Sprintf("%s@%s:%s/", host.Username, host.IP, host.RemoteDir),
-		host.MountPath,
-		"-o", fmt.Sprintf("%s,port=%d", MOUNT_OPTIONS, host.Port))
-	
-	err := cmd.Run()
-	result.MountTime = time.Since(mountStart)
-	
-	if err != nil {
-		result.Error = fmt.Errorf("failed to mount: %v", err)
-		msg := fmt.Sprintf("Failed to mount: %s:%d (%.6fs)", host.IP, host.Port, result.MountTime.Seconds())
-		if daemonMode {
-			logMessage(msg)
-		}
-		return result
-	}
-
-	result.Mounted = true
-	msg := fmt.Sprintf("Successfully mounted: %s:%d -> %s (%.6fs)", host.IP, host.Port, host.MountPath, result.MountTime.Seconds())
-	if daemonMode {
-		logMessage(msg)
-	}
-
-	// Get remote info after successful mount
-	result.RemoteInfo = RemoteInfo{
-		Hostname: getRemoteInfo(host, "hostname"),
-		Uptime:   getRemoteInfo(host, "uptime"),
-		MAC:      getRemoteInfo(host, "mac"),
-	}
-
-	return result
+// statusBadges holds the colors and label of each status badge. Unknown
+// statuses show as OFFLINE.
+var statusBadges = map[string]struct{ colors, label string }{
+	"ONLINE":             {bgGreen + colorBlue, "ONLINE"},
+	"STALE":              {bgYellow + colorBlue, "STALE"},
+	"DEGRADED":           {bgYellow + colorBlue, "DEGRADED"},
+	"CONN-ERR":           {bgYellow + colorBlue, "CONN-ERR"},
+	"SSH-DOWN":           {bgRed + colorWhite, "SSH-DOWN"},
+	"READONLY-VIOLATION": {bgRed + colorWhite, "RO-VIOLATION"},
+	"SHADOWED":           {bgYellow + colorBlue, "SHADOWED"},
+	"SKIPPED":            {bgYellow + colorBlue, "SKIPPED"},
+	"OFFLINE":            {bgRed + colorWhite, "OFFLINE"},
 }
 
-func processHostsParallel(hosts []Host) []HostResult {
-	var wg sync.WaitGroup
-	results := make([]HostResult, len(hosts))
-	
-	for i, host := range hosts {
-		wg.Add(1)
-		go func(index int, h Host) {
-			defer wg.Done()
-			results[index] = mountHost(h)
-		}(i, host)
+// badgeWidth is the width of the widest badge label; every badge is padded
+// to it so the columns after the badge line up.
+var badgeWidth = func() int {
+	width := 0
+	for _, badge := range statusBadges {
+		width = max(width, displayWidth(badge.label))
 	}
-	
-	wg.Wait()
-	return results
+	return width
+}()
+
+func getStatusBadge(result sshfsmon.HostResult) string {
+	return statusBadge(monitor.Status(result))
 }
 
-func getStatusBadge(result HostResult) string {
-	if result.Reachable {
-		if result.Mounted {
-			// Check if mount is still accessible
-			cmd := exec.Command("mountpoint", "-q", result.Host.MountPath)
-			if err := cmd.Run(); err == nil {
-				return fmt.Sprintf("%s%s%s ONLINE  %s", bgGreen, colorBlue, colorBold, colorReset)
-			} else {
-				return fmt.Sprintf("%s%s%s STALE   %s", bgYellow, colorBlue, colorBold, colorReset)
-			}
-		} else {
-			return fmt.Sprintf("%s%s%s CONN-ERR%s", bgYellow, colorBlue, colorBold, colorReset)
-		}
-	} else {
-		return fmt.Sprintf("%s%s%s OFFLINE %s", bgRed, colorWhite, colorBold, colorReset)
+func statusBadge(status string) string {
+	badge, ok := statusBadges[status]
+	if !ok {
+		badge = statusBadges["OFFLINE"]
 	}
+	return fmt.Sprintf("%s%s %s %s", badge.colors, colorBold, padRight(badge.label, badgeWidth), colorReset)
 }
 
-func printBootstrapStatus(results []HostResult) {
-	// Clear screen and move cursor to top
-	fmt.Print("\033[?25l\033[H\033[2J")
+func printBootstrapStatus(results []sshfsmon.HostResult, view viewState) {
+	// Render into a buffer; drawFrame puts it on the screen
+	var frame bytes.Buffer
 	
 	localHostname := getLocalInfo("hostname")
 	localUptime := getLocalInfo("uptime")
 	localMAC := getLocalInfo("mac")
 	
+	// Size the boxes to the terminal; host lines too long for it are cut
+	inner, lineWidth, usageCells := dashboardLayout()
+	fitLine := func(line string) {
+		if lineWidth > 0 {
+			line = truncate(line, lineWidth)
+		}
+		frame.WriteString(line + "\n")
+	}
+	
 	// Header
-	fmt.Printf("%s%s╔══════════════════════════════════════════════════════════════╗%s\n", colorBold, colorCyan, colorReset)
-	fmt.Printf("%s%s║                    SSHFS STATUS MONITOR                     ║%s\n", colorBold, colorCyan, colorReset)
+	title := "SSHFS STATUS MONITOR"
+	fmt.Fprintf(&frame, "%s%s╔%s╗%s\n", colorBold, colorCyan, strings.Repeat("═", inner), colorReset)
+	fmt.Fprintf(&frame, "%s%s║%s║%s\n", colorBold, colorCyan, padRight(strings.Repeat(" ", (inner-len(title))/2)+title, inner), colorReset)
 	
 	// Local info
 	localInfo := fmt.Sprintf("  Local: %s | Uptime: %s", localHostname, localUptime)
-	localPadding := 62 - len(localInfo)
-	fmt.Printf("%s%s║%s%s%s║%s\n", colorBold, colorCyan, localInfo, strings.Repeat(" ", localPadding), colorBold, colorReset)
+	fmt.Fprintf(&frame, "%s%s║%s%s║%s\n", colorBold, colorCyan, padRight(truncate(localInfo, inner), inner), colorBold, colorReset)
 	
 	macInfo := fmt.Sprintf("  MAC: %s", localMAC)
-	macPadding := 62 - len(macInfo)
-	fmt.Printf("%s%s║%s%s%s║%s\n", colorBold, colorCyan, macInfo, strings.Repeat(" ", macPadding), colorBold, colorReset)
+	fmt.Fprintf(&frame, "%s%s║%s%s║%s\n", colorBold, colorCyan, padRight(truncate(macInfo, inner), inner), colorBold, colorReset)
 	
-	fmt.Printf("%s%s╚══════════════════════════════════════════════════════════════╝%s\n", colorBold, colorCyan, colorReset)
-	fmt.Println()
+	fmt.Fprintf(&frame, "%s%s╚%s╝%s\n", colorBold, colorCyan, strings.Repeat("═", inner), colorReset)
+	fmt.Fprintln(&frame)
+	
+	if hostsReloadError != "" {
+		fitLine(fmt.Sprintf("  %s%s%s RELOAD FAILED %s %sshowing the last loaded hosts:%s %s",
+			bgRed, colorWhite, colorBold, colorReset, colorRed, colorReset, hostsReloadError))
+		fmt.Fprintln(&frame)
+	}
 	
 	totalHosts := len(results)
 	onlineHosts := 0
-	
-	for i, result := range results {
+	for _, result := range results {
 		if result.Reachable {
 			onlineHosts++
 		}
-		
+	}
+	
+	for _, i := range view.order(results) {
+		result := results[i]
 		badge := getStatusBadge(result)
+		if result.ConsecutiveFailures > 0 {
+			badge += fmt.Sprintf(" %s(x%d)%s", colorRed, result.ConsecutiveFailures, colorReset)
+		}
+		if monitor.SlowMount(result) {
+			badge += fmt.Sprintf(" %s(slow mount %.1fs)%s", colorYellow, result.MountTime.Seconds(), colorReset)
+		}
 		hostLabel := fmt.Sprintf("Host %d", i+1)
+		if result.Host.Label != "" {
+			hostLabel = result.Host.Label
+		}
+		if len(result.Host.Tags) > 0 {
+			hostLabel += fmt.Sprintf(" %s[%s]%s", colorCyan, strings.Join(result.Host.Tags, ","), colorReset)
+		}
+		
+		// Host and Up get columns of their own, the other remote info
+		// fields share the line below. All are left out when the probes
+		// are disabled.
+		var hostColumn, upColumn string
+		var extraFields []string
+		if config.RemoteInfo {
+			for _, field := range config.RemoteFields {
+				value := result.RemoteInfo.Field(field)
+				if !result.Reachable {
+					value = "N/A"
+				}
+				switch field {
+				case "hostname":
+					hostColumn = " | Host: " + value
+				case "uptime":
+					upColumn = " | Up: " + value
+				default:
+					extraFields = append(extraFields, remoteFieldLabels[field]+": "+value)
+				}
+			}
+		}
 		
 		var pingDisplay string
 		if result.Reachable {
@@ -470,140 +798,180 @@ func printBootstrapStatus(results []HostResult) {
 		
 		if result.Reachable {
 			if result.Mounted {
-				// Get disk usage
-				cmd := exec.Command("df", "-h", result.Host.MountPath)
-				var usage string
-				if output, err := cmd.Output(); err == nil {
-					lines := strings.Split(string(output), "\n")
-					if len(lines) > 1 {
-						fields := strings.Fields(lines[1])
-						if len(fields) >= 5 {
-							usagePercent := strings.TrimSuffix(fields[4], "%")
-							if percent, err := strconv.Atoi(usagePercent); err == nil {
-								// Create usage bar
-								barLength := percent / 10
-								usageBar := ""
-								for j := 0; j < 10; j++ {
-									if j < barLength {
-										usageBar += "█"
-									} else {
-										usageBar += "░"
-									}
-								}
-								usage = fmt.Sprintf("[%s %s%%]", usageBar, usagePercent)
-							}
-						}
-					}
+				usage := renderUsage(result.Host.MountPath, usageCells)
+				if showTraffic {
+					usage += fmt.Sprintf(" ↓%s ↑%s", formatBytes(result.IORead), formatBytes(result.IOWritten))
 				}
-				if usage == "" {
-					usage = "[N/A]"
-				}
-				
-				fmt.Printf("  %s %s (%s@%s) | Host: %s | Ping: %s | Mount: %s %s | Up: %s\n", 
-					badge, hostLabel, result.Host.Username, result.Host.IP, result.RemoteInfo.Hostname, pingDisplay, result.Host.MountPath, usage, result.RemoteInfo.Uptime)
-				fmt.Printf("    %s└─ MAC: %s%s\n", colorDim, result.RemoteInfo.MAC, colorReset)
+				fitLine(fmt.Sprintf("  %s %s (%s@%s)%s | Ping: %s | Mount: %s %s%s", 
+					badge, hostLabel, result.Host.Username, result.Host.IP, hostColumn, pingDisplay, result.Host.MountPath, usage, upColumn))
 			} else {
-				fmt.Printf("  %s %s (%s@%s) | Host: %s | Ping: %s | Mount: Failed to connect | Up: %s\n", 
-					badge, hostLabel, result.Host.Username, result.Host.IP, result.RemoteInfo.Hostname, pingDisplay, result.RemoteInfo.Uptime)
-				fmt.Printf("    %s└─ MAC: %s%s\n", colorDim, result.RemoteInfo.MAC, colorReset)
+				fitLine(fmt.Sprintf("  %s %s (%s@%s)%s | Ping: %s | Mount: Failed to connect%s", 
+					badge, hostLabel, result.Host.Username, result.Host.IP, hostColumn, pingDisplay, upColumn))
 			}
 		} else {
-			fmt.Printf("  %s %s (%s@%s) | Host: N/A | Ping: N/A | Mount: Not available | Up: N/A\n", 
-				badge, hostLabel, result.Host.Username, result.Host.IP)
-			fmt.Printf("    %s└─ MAC: N/A%s\n", colorDim, colorReset)
+			fitLine(fmt.Sprintf("  %s %s (%s@%s)%s | Ping: N/A | Mount: Not available%s", 
+				badge, hostLabel, result.Host.Username, result.Host.IP, hostColumn, upColumn))
+		}
+		if len(extraFields) > 0 {
+			fitLine(fmt.Sprintf("    %s└─ %s%s", colorDim, strings.Join(extraFields, " | "), colorReset))
 		}
 	}
 	
+	if totalHosts == 0 && noHostsReason != "" {
+		fmt.Fprintf(&frame, "  %sNo hosts configured:%s %s\n", colorYellow, colorReset, noHostsReason)
+		fmt.Fprintln(&frame)
+	}
+	
 	// Summary
-	fmt.Printf("%s%s┌─ SUMMARY ─────────────────────────────────────────────────────┐%s\n", colorBold, colorBlue, colorReset)
+	fmt.Fprintf(&frame, "%s%s┌─ SUMMARY %s┐%s\n", colorBold, colorBlue, strings.Repeat("─", inner+1-10), colorReset)
 	
 	successRate := 0
 	if totalHosts > 0 {
 		successRate = (onlineHosts * 100) / totalHosts
 	}
 	summaryInfo := fmt.Sprintf(" Total: %d hosts │ Online: %d hosts │ Success: %d%%", totalHosts, onlineHosts, successRate)
-	summaryPadding := 62 - len(summaryInfo)
-	fmt.Printf("%s%s│%s%s%s│%s\n", colorBold, colorBlue, summaryInfo, strings.Repeat(" ", summaryPadding), colorBold, colorReset)
+	fmt.Fprintf(&frame, "%s%s│%s%s│%s\n", colorBold, colorBlue, padRight(truncate(summaryInfo, inner+1), inner+1), colorBold, colorReset)
 	
-	fmt.Printf("%s%s└───────────────────────────────────────────────────────────────┘%s\n", colorBold, colorBlue, colorReset)
-	fmt.Println()
-	fmt.Printf("%sLast updated: %s%s\n", colorDim, time.Now().Format("2006-01-02 15:04:05"), colorReset)
+	fmt.Fprintf(&frame, "%s%s└%s┘%s\n", colorBold, colorBlue, strings.Repeat("─", inner+1), colorReset)
+	fmt.Fprintln(&frame)
+	fmt.Fprintf(&frame, "%sLast updated: %s%s\n", colorDim, time.Now().Format("2006-01-02 15:04:05"), colorReset)
 	
 	if daemonMode {
-		fmt.Printf("%sNext check in: %ds | Press Ctrl+C to stop%s\n", colorDim, CHECK_INTERVAL, colorReset)
+		fmt.Fprintf(&frame, "%sNext check in: %s | Press Ctrl+C to stop%s\n", colorDim, checkInterval, colorReset)
+	}
+	if view.interactive {
+		fmt.Fprintf(&frame, "%s%s%s\n", colorDim, view.help(), colorReset)
+	}
+	if notices := screenNotices.take(); len(notices) > 0 {
+		fmt.Fprintln(&frame)
+		for _, notice := range notices {
+			fmt.Fprintf(&frame, "%s%s%s\n", colorYellow, notice, colorReset)
+		}
 	}
 	
-	// Show cursor
-	fmt.Print("\033[?25h")
+	drawFrame(frame.String())
+}
+
+// resultSummary counts the outcomes of a round of mounts, for the SUMMARY
+// section of once.
+type resultSummary struct {
+	total       int
+	reachable   int
+	mounted     int
+	sshDown     int
+	mountFailed int // reachable and ssh answering, but not mounted
+	skipped     int // waiting for a host that did not mount
 }
 
-func printStats(results []HostResult, totalTime time.Duration) {
+func summarizeResults(results []sshfsmon.HostResult) resultSummary {
+	summary := resultSummary{total: len(results)}
+	for _, result := range results {
+		if result.Skipped {
+			summary.skipped++
+		}
+		if !result.Reachable {
+			continue
+		}
+		summary.reachable++
+		switch {
+		case result.Mounted:
+			summary.mounted++
+		case !result.SSHReachable:
+			summary.sshDown++
+		default:
+			summary.mountFailed++
+		}
+	}
+	return summary
+}
+
+// printSummary prints the SUMMARY section of once.
+func printSummary(summary resultSummary) {
+	fmt.Println("SUMMARY:")
+	fmt.Printf("  Total hosts configured: %d\n", summary.total)
+	fmt.Printf("  Hosts reachable: %d\n", summary.reachable)
+	fmt.Printf("  Hosts mounted: %d\n", summary.mounted)
+
+	successRate := 0
+	if summary.reachable > 0 {
+		successRate = (summary.mounted * 100) / summary.reachable
+	}
+	fmt.Printf("  Success rate: %d%%\n", successRate)
+	fmt.Println("  Breakdown:")
+	fmt.Printf("    %sMounted:                  %d%s\n", colorGreen, summary.mounted, colorReset)
+	fmt.Printf("    %sReachable, mount failed:  %d%s\n", colorYellow, summary.mountFailed, colorReset)
+	fmt.Printf("    %sReachable, SSH down:      %d%s\n", colorRed, summary.sshDown, colorReset)
+	fmt.Printf("    %sUnreachable:              %d%s\n", colorRed, summary.total-summary.reachable-summary.skipped, colorReset)
+	if summary.skipped > 0 {
+		fmt.Printf("    %sSkipped, dependency:      %d%s\n", colorYellow, summary.skipped, colorReset)
+	}
+}
+
+func printStats(results []sshfsmon.HostResult, totalTime time.Duration) {
 	fmt.Println()
 	fmt.Println("==================== SSHFS CONNECTION STATS ====================")
+	if config.MountTimeWarn > 0 {
+		fmt.Printf("Slow mount warning above %s\n", config.MountTimeWarn)
+	}
 	fmt.Printf("%-18s %-12s %-12s %-15s %-15s\n", "HOST", "STATUS", "PING (ms)", "PING TIME", "MOUNT TIME")
 	fmt.Println("------------------------------------------------------------------")
 	
-	totalHosts := len(results)
-	reachableHosts := 0
-	mountedHosts := 0
-	
 	for _, result := range results {
 		status := "UNREACHABLE"
+		if result.Skipped {
+			status = "SKIPPED"
+		}
 		pingTimeStr := "N/A"
 		mountStatus := "N/A"
+		mountColor := ""
 		
 		if result.Reachable {
 			status = "REACHABLE"
-			reachableHosts++
 			pingTimeStr = fmt.Sprintf("%.3f", float64(result.PingTime.Nanoseconds())/1e6)
 			
 			if result.Mounted {
-				if result.ExecutedCmd == "already_mounted" {
+				if result.ExecutedCmd == sshfsmon.ALREADY_MOUNTED {
 					mountStatus = "ALREADY MOUNTED"
 				} else {
 					mountStatus = fmt.Sprintf("SUCCESS (%.6fs)", result.MountTime.Seconds())
+					if monitor.SlowMount(result) {
+						mountStatus = fmt.Sprintf("SLOW (%.6fs)", result.MountTime.Seconds())
+						mountColor = colorYellow
+					}
+				}
+				if result.ReadOnlyViolation {
+					mountStatus = "READONLY-VIOLATION"
+					mountColor = colorRed
 				}
-				mountedHosts++
+			} else if !result.SSHReachable {
+				mountStatus = "SSH DOWN"
+			} else if result.Shadowed {
+				mountStatus = "SHADOWED"
+				mountColor = colorYellow
 			} else if result.Error != nil {
 				mountStatus = fmt.Sprintf("FAILED (%.6fs)", result.MountTime.Seconds())
 			}
 		}
 		
+		if mountColor != "" {
+			mountStatus = mountColor + fmt.Sprintf("%-15s", mountStatus) + colorReset
+		}
 		fmt.Printf("%-18s %-12s %-12s %-15s %-15s\n",
-			fmt.Sprintf("%s@%s", result.Host.Username, result.Host.IP), status, pingTimeStr, 
+			displayName(result.Host), status, pingTimeStr, 
 			fmt.Sprintf("%.6fs", result.CheckTime.Seconds()), mountStatus)
 	}
 	
 	fmt.Println("=================================================================")
-	fmt.Println("SUMMARY:")
-	fmt.Printf("  Total hosts configured: %d\n", totalHosts)
-	fmt.Printf("  Hosts reachable: %d\n", reachableHosts)
-	fmt.Printf("  Hosts mounted: %d\n", mountedHosts)
-	
-	successRate := 0
-	if reachableHosts > 0 {
-		successRate = (mountedHosts * 100) / reachableHosts
-	}
-	fmt.Printf("  Success rate: %d%%\n", successRate)
+	summary := summarizeResults(results)
+	printSummary(summary)
 	fmt.Println()
 	
-	if mountedHosts > 0 {
+	if summary.mounted > 0 {
 		fmt.Println("Active mount points:")
 		for _, result := range results {
 			if result.Mounted {
-				cmd := exec.Command("df", "-h", result.Host.MountPath)
-				output, err := cmd.Output()
-				if err == nil {
-					lines := strings.Split(string(output), "\n")
-					if len(lines) > 1 {
-						fields := strings.Fields(lines[1])
-						if len(fields) >= 5 {
-							usage := fmt.Sprintf("%s used: %s (%s)", fields[1], fields[2], fields[4])
-							fmt.Printf("  %s -> %s@%s:%d:%s/ [%s]\n", 
-								result.Host.MountPath, result.Host.Username, result.Host.IP, result.Host.Port, result.Host.RemoteDir, usage)
-						}
-					}
-				}
+				fmt.Printf("  %s -> %s@%s:%d:%s/ %s\n", 
+					result.Host.MountPath, result.Host.Username, result.Host.IP, result.Host.Port, result.Host.RemoteDir, renderUsage(result.Host.MountPath, USAGE_BAR))
 			}
 		}
 	}
@@ -618,13 +986,29 @@ func printStats(results []HostResult, totalTime time.Duration) {
 			fmt.Sprintf("%s@%s", result.Host.Username, result.Host.IP), result.Host.Port, result.Host.MountPath, result.Host.RemoteDir)
 	}
 	
-	fmt.Println()
+	if config.RemoteInfo {
+		fmt.Println()
+		fmt.Println("Remote Info Probes:")
+		// Mounts of one server share the probes of the first one
+		for _, result := range results {
+			if result.Mounted && result.RemoteInfoTime > 0 {
+				fmt.Printf("  %-18s %.6fs\n",
+					fmt.Sprintf("%s@%s", result.Host.Username, result.Host.IP), result.RemoteInfoTime.Seconds())
+			}
+		}
+	}
+	
 	fmt.Println("Actual SSHFS Commands Executed:")
 	for _, result := range results {
-		if result.ExecutedCmd != "" && result.ExecutedCmd != "already_mounted" {
+		if result.ExecutedCmd != "" && result.ExecutedCmd != sshfsmon.ALREADY_MOUNTED {
 			fmt.Printf("  %s\n", result.ExecutedCmd)
-		} else if result.ExecutedCmd == "already_mounted" {
+			if result.Error != nil {
+				fmt.Printf("    %s└─ %v%s\n", colorRed, result.Error, colorReset)
+			}
+		} else if result.ExecutedCmd == sshfsmon.ALREADY_MOUNTED {
 			fmt.Printf("  %s@%s: Already mounted, no command executed\n", result.Host.Username, result.Host.IP)
+		} else if result.Error != nil {
+			fmt.Printf("  %s@%s: No command executed (%v)\n", result.Host.Username, result.Host.IP, result.Error)
 		} else {
 			fmt.Printf("  %s@%s: No command executed (host not reachable)\n", result.Host.Username, result.Host.IP)
 		}
@@ -633,8 +1017,19 @@ func printStats(results []HostResult, totalTime time.Duration) {
 	fmt.Printf("Total execution time: %.6fs\n", totalTime.Seconds())
 }
 
-func monitorAndMount(hosts []Host) int {
-	results := processHostsParallel(hosts)
+// monitorAndMount runs the daemon's mounts for one cycle, giving up on them
+// at the cycle deadline. It then returns no results but how many hosts were
+// still mounting, and false.
+func monitorAndMount(hosts []sshfsmon.Host) ([]sshfsmon.HostResult, int, bool) {
+	deadline := effectiveCycleDeadline()
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+	results, inFlight := monitor.ProcessHostsUntil(ctx, hosts)
+	if ctx.Err() != nil && results == nil {
+		logMessage(fmt.Sprintf("WARN: Cycle passed its %s deadline with %d of %d hosts still mounting; moving on, they are skipped until they finish",
+			deadline, inFlight, len(hosts)))
+		return nil, inFlight, false
+	}
 	mountedCount := 0
 	
 	for _, result := range results {
@@ -647,44 +1042,137 @@ func monitorAndMount(hosts []Host) int {
 		logMessage(fmt.Sprintf("Monitoring cycle complete: %d hosts mounted", mountedCount))
 	}
 	
-	return mountedCount
+	return results, 0, true
 }
 
 func startDaemon() {
-	// Check if already running
-	if _, err := os.Stat(PID_FILE); err == nil {
-		pidData, err := ioutil.ReadFile(PID_FILE)
-		if err == nil {
-			pid := strings.TrimSpace(string(pidData))
-			// Check if process is still running
-			if err := exec.Command("kill", "-0", pid).Run(); err == nil {
-				fmt.Printf("SSHFS monitor already running (PID: %s)\n", pid)
-				os.Exit(1)
-			}
-			// Remove stale PID file
-			os.Remove(PID_FILE)
+	// Under a supervisor there is no PID file; it tracks the process itself
+	var pidFile *heldPidFile
+	var err error
+	if !foreground {
+		pidFile, err = acquirePidFile(osPidFS{}, pidPath, os.Getpid())
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
 	}
-	
-	// Write PID file
 	pid := os.Getpid()
-	err := ioutil.WriteFile(PID_FILE, []byte(strconv.Itoa(pid)), 0644)
-	if err != nil {
-		log.Fatalf("Failed to write PID file: %v", err)
-	}
 	
 	// Initialize logging
 	if err := initLogging(); err != nil {
+		releasePidFile(pidFile)
 		log.Fatalf("Failed to initialize logging: %v", err)
 	}
-	defer logFile.Close()
+	defer func() {
+		logMu.Lock()
+		logFile.Close()
+		logMu.Unlock()
+	}()
 	
 	daemonMode = true
-	logMessage(fmt.Sprintf("SSHFS monitor started in daemon mode (PID: %d)", pid))
+	recentLogs = newLogRing(logBufferSize)
+	hostLog = newHostLogger(logRepeatEvery)
+	if foreground {
+		logMessage(fmt.Sprintf("SSHFS monitor started in the foreground (PID: %d)", pid))
+	} else {
+		logMessage(fmt.Sprintf("SSHFS monitor started in daemon mode (PID: %d)", pid))
+	}
+	logMessage("Effective configuration: " + effectiveConfig())
+	
+	// Load hosts
+	state := &daemonState{}
+	if err := state.reload(); err != nil {
+		logMessage(fmt.Sprintf("Error loading hosts: %v", err))
+		releasePidFile(pidFile)
+		os.Exit(1)
+	}
+	if err := mountAccessError(state.currentHosts()); err != nil {
+		if !allowNonroot {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			logMessage(fmt.Sprintf("ERROR: %v", err))
+			releaseMounts()
+			releasePidFile(pidFile)
+			os.Exit(1)
+		}
+		logMessage(fmt.Sprintf("WARN: %v", err))
+	}
+	
+	// Debounce flapping hosts. Set before the control socket and the mount
+	// watcher start, as both may mount from their own goroutines.
+	if remountAfter > 1 {
+		monitor.MayMount = state.mayMount
+	}
+	if staleAfter > 1 {
+		monitor.MayClear = state.mayClear
+	}
+	monitor.MountedAt = state.mountTime
+	
+	// Optional control socket
+	var control net.Listener
+	if controlSocket != "" {
+		control, err = listenControl(controlSocket)
+		if err != nil {
+			logMessage(fmt.Sprintf("Error opening control socket: %v", err))
+			releaseMounts()
+			releasePidFile(pidFile)
+			os.Exit(1)
+		}
+		logMessage(fmt.Sprintf("Control socket listening on %s", controlSocket))
+		go serveControl(control, state)
+	}
+	
+	// Optional event-driven remounts on top of the periodic scan
+	var watcher *mountWatcher
+	if watchMounts {
+		watcher, err = newMountWatcher(state)
+		if err != nil {
+			logMessage(fmt.Sprintf("Mount watching disabled, polling only: %v", err))
+		} else {
+			go watcher.run()
+		}
+	}
+	
+	var profiler *http.Server
+	if pprofAddr != "" {
+		profiler, err = startPprof(pprofAddr)
+		if err != nil {
+			logMessage(fmt.Sprintf("Error opening pprof server: %v", err))
+			if control != nil {
+				control.Close()
+				os.Remove(controlSocket)
+			}
+			releaseMounts()
+			releasePidFile(pidFile)
+			os.Exit(1)
+		}
+		logMessage(fmt.Sprintf("Serving pprof profiles on %s", httpURL(pprofAddr, "/debug/pprof/")))
+	}
+	
+	var history *historyWriter
+	if historyFile != "" {
+		history = newHistoryWriter(historyFile, historyRetention)
+		go history.run()
+	}
+	
+	var reaper *idleReaper
+	if idleTimeout > 0 {
+		reaper = newIdleReaper(idleTimeout)
+	}
+	
+	var accounting *ioAccounting
+	if ioStats {
+		accounting = newIOAccounting()
+	}
 	
 	// Setup signal handling
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	// SIGUSR1 runs a cycle right away. The channel holds one pending
+	// trigger, so signals arriving during a cycle coalesce into one more.
+	usr1Chan := make(chan os.Signal, 1)
+	signal.Notify(usr1Chan, syscall.SIGUSR1)
 	
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -692,166 +1180,545 @@ func startDaemon() {
 	go func() {
 		<-sigChan
 		logMessage("Received shutdown signal, cleaning up...")
-		os.Remove(PID_FILE)
+		if control != nil {
+			control.Close()
+			os.Remove(controlSocket)
+		}
+		if profiler != nil {
+			profiler.Close()
+		}
+		releaseMounts()
+		releasePidFile(pidFile)
 		logMessage("SSHFS monitor stopped")
 		cancel()
 	}()
 	
-	// Load hosts
-	hosts, err := loadHosts()
-	if err != nil {
-		logMessage(fmt.Sprintf("Error loading hosts: %v", err))
-		os.Exit(1)
+	// One monitoring cycle. Only the loop below runs it, so cycles never
+	// overlap.
+	runCycle := func() {
+		cycleStart := time.Now()
+		if reaper != nil {
+			// Without a working watcher to remount on demand, reaped
+			// hosts sit out one cycle and are then mounted again
+			if !watcher.onDemand() {
+				state.clearReaped()
+			}
+			reaper.reapIdle(state)
+		}
+		results, inFlight, finished := monitorAndMount(state.breakerHosts(state.cycleHosts()))
+		state.recordCycleTime(time.Since(cycleStart), inFlight, !finished)
+		if !finished {
+			// The last results stand until a cycle finishes
+			return
+		}
+		state.keepInFlight(results)
+		if accounting != nil {
+			accounting.sample(results)
+		}
+		state.recordCycle(results)
+		if escalateAfter > 0 {
+			state.escalateHung()
+		}
+		if history != nil {
+			history.record(state.latestResults())
+		}
+		hostLog.sweep(cycleStart)
+		if reaper != nil {
+			reaper.snapshot(state.latestResults())
+		}
+		if watcher != nil {
+			watcher.arm(state.latestResults())
+			watcher.armReaped(state.reapedPaths())
+		}
 	}
 	
-	// Main daemon loop
-	ticker := time.NewTicker(CHECK_INTERVAL * time.Second)
+	// Main daemon loop, with quick cycles after startup while hosts are
+	// not mounted yet
+	ticker := time.NewTicker(checkInterval)
 	defer ticker.Stop()
+	boot := newBootRetry()
 	
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-hupChan:
+			if err := reopenLog(); err != nil {
+				logMessage(fmt.Sprintf("ERROR: %v, still logging to the old file", err))
+			}
+			logMessage("Received SIGHUP, reopened the log file, reloading hosts")
+			if err := state.reload(); err != nil {
+				logMessage(fmt.Sprintf("Reload failed, keeping previous hosts: %v", err))
+			}
+		case <-usr1Chan:
+			logMessage("Received SIGUSR1, running a manual cycle now")
+			runCycle()
+			boot.after(state.latestResults())
+		case <-boot.timer:
+			runCycle()
+			boot.after(state.latestResults())
 		case <-ticker.C:
-			monitorAndMount(hosts)
+			runCycle()
+			boot.after(state.latestResults())
 		}
 	}
 }
 
 func stopDaemon() {
-	if _, err := os.Stat(PID_FILE); err != nil {
-		fmt.Println("SSHFS monitor not running")
-		return
-	}
-	
-	pidData, err := ioutil.ReadFile(PID_FILE)
-	if err != nil {
-		fmt.Printf("Error reading PID file: %v\n", err)
-		return
-	}
-	
-	pid := strings.TrimSpace(string(pidData))
-	
-	// Check if process is running
-	if err := exec.Command("kill", "-0", pid).Run(); err != nil {
-		fmt.Println("SSHFS monitor not running")
-		os.Remove(PID_FILE)
-		return
-	}
-	
-	// Kill the process
-	fmt.Printf("Stopping SSHFS monitor (PID: %s)...\n", pid)
-	if err := exec.Command("kill", pid).Run(); err != nil {
-		fmt.Printf("Error stopping daemon: %v\n", err)
-		return
-	}
-	
-	// Wait for graceful shutdown
-	time.Sleep(2 * time.Second)
-	
-	// Force kill if still running
-	if err := exec.Command("kill", "-0", pid).Run(); err == nil {
-		exec.Command("kill", "-9", pid).Run()
-		fmt.Println("Force killed SSHFS monitor")
+	if err := stopProcess(osPidFS{}, osProcesses{}, pidPath, STOP_GRACE, os.Stdout); err != nil {
+		fmt.Println(err)
 	}
-	
-	os.Remove(PID_FILE)
-	fmt.Println("SSHFS monitor stopped")
 }
 
 func statusDaemon() {
-	if _, err := os.Stat(PID_FILE); err != nil {
-		fmt.Println("SSHFS monitor not running")
-		os.Exit(1)
+	if jsonOutput {
+		os.Exit(statusJSONMode())
 	}
-	
-	pidData, err := ioutil.ReadFile(PID_FILE)
+	daemon, err := checkDaemon(osPidFS{}, pidPath)
 	if err != nil {
-		fmt.Printf("Error reading PID file: %v\n", err)
+		fmt.Println(err)
 		os.Exit(1)
 	}
-	
-	pid := strings.TrimSpace(string(pidData))
-	
-	if err := exec.Command("kill", "-0", pid).Run(); err != nil {
+	switch daemon.State {
+	case daemonNotRunning:
+		fmt.Println("SSHFS monitor not running")
+		os.Exit(1)
+	case daemonStale:
 		fmt.Println("SSHFS monitor not running (stale PID file)")
-		os.Remove(PID_FILE)
 		os.Exit(1)
 	}
 	
-	fmt.Printf("SSHFS monitor running (PID: %s)\n", pid)
-	fmt.Printf("Log file: %s\n", LOG_FILE)
-	fmt.Printf("Check interval: %ds\n", CHECK_INTERVAL)
+	fmt.Printf("SSHFS monitor running (PID: %d)\n", daemon.PID)
+	fmt.Printf("Log: %s\n", logDestination())
+	fmt.Printf("Check interval: %s\n", checkInterval)
+
+	// The recent log lines live in the daemon, reachable via its socket
+	if controlSocket != "" {
+		var cycle cycleStats
+		if err := controlQuery(controlSocket, "cycle", &cycle); err == nil && cycle.FinishedAt != "" {
+			overran := ""
+			if cycle.Overran {
+				overran = fmt.Sprintf(", OVERRAN with %d hosts still mounting", cycle.InFlight)
+			}
+			deadline := time.Duration(cycle.DeadlineSeconds * float64(time.Second))
+			fmt.Printf("Last cycle: %.3fs of its %s deadline%s\n", cycle.Seconds, deadline, overran)
+		}
+		lines, err := recentLogLines(controlSocket, 20)
+		if err != nil {
+			fmt.Printf("Recent log unavailable: %v\n", err)
+			return
+		}
+		fmt.Println()
+		fmt.Println("Recent log:")
+		for _, line := range lines {
+			fmt.Printf("  %s\n", line)
+		}
+	}
 }
 
 func followLogs() {
-	cmd := exec.Command("tail", "-f", LOG_FILE)
+	if useSyslog {
+		fmt.Printf("The daemon logs to syslog with tag %s; follow it with: journalctl -t %s -f\n", SYSLOG_TAG, SYSLOG_TAG)
+		if syslogOnly() {
+			return
+		}
+	}
+	cmd := exec.Command("tail", "-f", logPath)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Run()
 }
 
 func watchMode() {
-	fmt.Println("Starting live status monitor (Press Ctrl+C to exit)...")
+	if watchOutput == "jsonl" {
+		watchJSONL()
+		return
+	}
+	if !quiet {
+		fmt.Println("Starting live status monitor (Press Ctrl+C to exit)...")
+	}
+	screenMode = true
 	
-	// Setup signal handling
+	// Setup signal handling; a hangup means the terminal went away
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
 	
 	go func() {
 		<-sigChan
-		fmt.Print("\033[?25h") // Show cursor
+		leaveWatch()
 		os.Exit(0)
 	}()
 	
-	hosts, err := loadHosts()
-	if err != nil {
-		log.Fatalf("Error loading hosts: %v", err)
+	hosts := loadScreenHosts()
+	if !requireMountAccess(hosts) {
+		os.Exit(EXIT_MOUNT_FAILED)
 	}
 	
+	// Redraw at the new size when the terminal is resized
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	
+	// Single-key controls when attached to a terminal
+	var view viewState
+	keys := startKeyReader()
+	view.interactive = keys != nil
+	// Also runs when a panic ends watch, before the crash is printed
+	defer leaveWatch()
+	
 	// Fast initial load
 	fmt.Print("\033[H\033[2J")
-	fmt.Println("Loading SSHFS monitor...")
+	if !quiet {
+		fmt.Println("Loading SSHFS monitor...")
+	}
 	
+	failures := make(map[string]int)
+	var accounting *ioAccounting
+	if ioStats {
+		accounting = newIOAccounting()
+		showTraffic = true
+	}
+	for {
+		// Reload errors are shown on screen instead of exiting
+		hosts = reloadScreenHosts(hosts)
+		results := monitor.ProcessHostsParallel(hosts)
+		trackFailures(results, failures)
+		if accounting != nil {
+			accounting.sample(results)
+		}
+		printBootstrapStatus(results, view)
+		
+		refresh := time.After(3 * time.Second)
+	wait:
+		for {
+			select {
+			case key := <-keys:
+				if key == 'q' {
+					return
+				}
+				if view.handleKey(key) {
+					printBootstrapStatus(results, view)
+				}
+			case <-winch:
+				clearScreen()
+				printBootstrapStatus(results, view)
+			case <-refresh:
+				break wait
+			}
+		}
+	}
+}
+
+// leaveWatch puts the terminal back the way watch found it, with line
+// editing, echo and the cursor.
+func leaveWatch() {
+	restoreTerminal()
+	fmt.Print("\033[?25h")
+}
+
+// watchJSONL is watch for log aggregators: every refresh writes one JSON
+// object per host to stdout, with no ANSI codes or screen clearing.
+func watchJSONL() {
+	var hosts []sshfsmon.Host
+	var lastErr string
+	encoder := json.NewEncoder(os.Stdout)
+	failures := make(map[string]int)
+	var accounting *ioAccounting
+	if ioStats {
+		accounting = newIOAccounting()
+	}
 	for {
-		results := processHostsParallel(hosts)
-		printBootstrapStatus(results)
+		if len(hosts) == 0 {
+			loaded, err := loadHosts()
+			if err != nil && err.Error() != lastErr {
+				fmt.Fprintf(os.Stderr, "No hosts configured: %v\n", err)
+				lastErr = err.Error()
+			}
+			hosts = loaded
+		}
+		results := monitor.ProcessHostsParallel(hosts)
+		trackFailures(results, failures)
+		if accounting != nil {
+			accounting.sample(results)
+		}
+		now := time.Now().Format(time.RFC3339)
+		for _, result := range results {
+			encoder.Encode(struct {
+				Time   string              `json:"time"`
+				Status string              `json:"status"`
+				Result sshfsmon.HostResult `json:"result"`
+			}{now, monitor.Status(result), result})
+		}
 		time.Sleep(3 * time.Second)
 	}
 }
 
 func dashboardMode() {
+	screenMode = true
+	hosts := loadScreenHosts()
+	if !requireMountAccess(hosts) {
+		os.Exit(EXIT_MOUNT_FAILED)
+	}
+	results := monitor.ProcessHostsParallel(hosts)
+	printBootstrapStatus(results, viewState{})
+}
+
+// findHosts returns the configured entries matching target, which may be a
+// bare IP or user@IP. A host listed with several mount paths yields several
+// entries.
+func findHosts(target string) ([]sshfsmon.Host, error) {
 	hosts, err := loadHosts()
 	if err != nil {
-		log.Fatalf("Error loading hosts: %v", err)
+		return nil, err
 	}
-	
-	results := processHostsParallel(hosts)
-	printBootstrapStatus(results)
+
+	var matched []sshfsmon.Host
+	for _, host := range hosts {
+		if host.IP == target || fmt.Sprintf("%s@%s", host.Username, host.IP) == target {
+			matched = append(matched, host)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("host %s not found in %s", target, hostsSource)
+	}
+	return matched, nil
+}
+
+// singleHostMode runs the mount or check command against one host and
+// returns the exit code. With several matching entries the worst outcome
+// wins.
+func singleHostMode(command string, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: ./sshfs-connector %s <ip|user@ip>\n", command)
+		return EXIT_USAGE
+	}
+
+	hosts, err := findHosts(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return EXIT_UNKNOWN_HOST
+	}
+	if command == "mount" && !requireMountAccess(hosts) {
+		return EXIT_MOUNT_FAILED
+	}
+
+	exitCode := EXIT_MOUNTED
+	for _, host := range hosts {
+		var code int
+		if command == "mount" {
+			code = mountOne(host)
+		} else {
+			code = checkOne(host)
+		}
+		if code > exitCode {
+			exitCode = code
+		}
+	}
+	monitor.WaitHooks()
+	return exitCode
+}
+
+func mountOne(host sshfsmon.Host) int {
+	result := monitor.MountHost(host)
+	switch {
+	case !result.Reachable:
+		fmt.Printf("%s@%s: not reachable\n", host.Username, host.IP)
+		return EXIT_UNREACHABLE
+	case !result.Mounted:
+		fmt.Printf("%s@%s: mount failed: %v\n", host.Username, host.IP, result.Error)
+		return EXIT_MOUNT_FAILED
+	case result.ExecutedCmd == sshfsmon.ALREADY_MOUNTED:
+		fmt.Printf("%s@%s: already mounted at %s\n", host.Username, host.IP, host.MountPath)
+	default:
+		fmt.Printf("%s@%s: mounted at %s (%.6fs)\n", host.Username, host.IP, host.MountPath, result.MountTime.Seconds())
+	}
+	return EXIT_MOUNTED
+}
+
+// checkOne probes a host without changing anything on disk.
+func checkOne(host sshfsmon.Host) int {
+	reachable, _, pingDuration := monitor.Ping(host)
+	if !reachable {
+		fmt.Printf("%s@%s: not reachable\n", host.Username, host.IP)
+		return EXIT_UNREACHABLE
+	}
+	if !sshfsmon.IsMountPoint(host.MountPath) {
+		fmt.Printf("%s@%s: reachable (%.3fms), not mounted at %s\n",
+			host.Username, host.IP, float64(pingDuration.Nanoseconds())/1e6, host.MountPath)
+		return EXIT_MOUNT_FAILED
+	}
+	fmt.Printf("%s@%s: reachable (%.3fms), mounted at %s\n",
+		host.Username, host.IP, float64(pingDuration.Nanoseconds())/1e6, host.MountPath)
+	return EXIT_MOUNTED
+}
+
+// onceExitCode applies --fail-on to the results of once, listing the hosts
+// that did not mount on stderr when the threshold is missed.
+func onceExitCode(results []sshfsmon.HostResult) int {
+	var failed []sshfsmon.HostResult
+	for _, result := range results {
+		if !result.Mounted {
+			failed = append(failed, result)
+		}
+	}
+	mounted := len(results) - len(failed)
+
+	var missed bool
+	if failOn == "any" {
+		missed = len(failed) > 0
+	} else {
+		percent, _ := strconv.Atoi(failOn)
+		missed = mounted*100 < percent*len(results)
+	}
+	if !missed {
+		return EXIT_MOUNTED
+	}
+
+	fmt.Fprintf(os.Stderr, "Threshold --fail-on=%s not met: %d of %d hosts mounted\n", failOn, mounted, len(results))
+	for _, result := range failed {
+		reason := "not reachable"
+		if result.Error != nil {
+			reason = result.Error.Error()
+		}
+		fmt.Fprintf(os.Stderr, "  %s@%s: %s\n", result.Host.Username, result.Host.IP, reason)
+	}
+	return EXIT_THRESHOLD
 }
 
 func showUsage() {
 	fmt.Println("SSHFS Auto-Mount Monitor (Go)")
 	fmt.Println()
-	fmt.Println("Usage: ./sshfs-connector {start|stop|restart|status|logs|once|watch|dashboard}")
+	fmt.Println("Usage: ./sshfs-connector {start|stop|restart|status|logs|once|watch|dashboard|mount|check|check-all|export-systemd|automap|validate|trust|history|benchmark|init|prune|reach}")
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println("  start      - Start daemon mode (continuous monitoring)")
 	fmt.Println("  stop       - Stop daemon mode")
 	fmt.Println("  restart    - Restart daemon mode")
-	fmt.Println("  status     - Show daemon status")
+	fmt.Println("  status     - Show daemon status (recent log with --control-socket)")
 	fmt.Println("  logs       - Follow log file")
 	fmt.Println("  once       - Run once with full stats")
 	fmt.Println("  watch      - Live Bootstrap-style status display (default)")
 	fmt.Println("  dashboard  - Single Bootstrap-style status snapshot")
+	fmt.Println("  mount IP   - Mount a single host from the hosts file and exit")
+	fmt.Println("  check IP   - Probe a single host without mounting it")
+	fmt.Println("  check-all  - Monitoring plugin check of all hosts (exit 0 OK, 1 WARNING, 2 CRITICAL)")
+	fmt.Println("  export-systemd - Print a systemd unit file for the daemon")
+	fmt.Println("  automap KEY    - Print the autofs program map entry for a host")
+	fmt.Println("  validate       - Check the hosts file without pinging or mounting")
+	fmt.Println("  trust          - Add every host's ssh keys to the --known-hosts file")
+	fmt.Println("  history HOST   - Show a host's uptime and ping over the last --since (daemon history)")
+	fmt.Println("  benchmark [HOST] - Mount and measure write/read throughput and stat latency per host")
+	fmt.Println("  init           - Write a commented sample hosts file to --hosts (--force overwrites)")
+	fmt.Println("  prune          - List empty mount directories of removed hosts (--prune removes them)")
+	fmt.Println("  reach          - Ping every host and knock on its SSH port, nothing else (as once --check-only)")
+	fmt.Println()
+	fmt.Println("Exit codes (mount, check):")
+	fmt.Println("  0 - mounted")
+	fmt.Println("  1 - reachable but not mounted")
+	fmt.Println("  2 - unreachable")
+	fmt.Println("  3 - host not in hosts file")
+	fmt.Println("  once exits 10 when --fail-on is not met")
+	fmt.Println("  reach exits 2 when a host is down or its SSH port closed")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --hosts SOURCE         Hosts list: file path, - for stdin, or http(s) URL")
+	fmt.Println("  --log-file, --pid-file FILE  Daemon log and PID file (defaults under /var/log, /var/run)")
+	fmt.Println("  --syslog               Log to syslog (tag sshfs-monitor) instead of the log file, or both with --log-file")
+	fmt.Println("  --syslog-facility F    Syslog facility: daemon (default), user, local0-local7")
+	fmt.Println("  --mount-registry FILE  Which daemon manages which mount paths (default under /var/run)")
+	fmt.Println("  --check-interval D     Time between daemon cycles (default 30s)")
+	fmt.Println("  --control-dir DIR      Directory for the --controlmaster sockets")
+	fmt.Println("  --hosts-format FORMAT  fields, csv or toml (default: by .csv/.toml extension, else fields)")
+	fmt.Println("  --tag TAG              Only act on hosts with tags=TAG; repeat for several")
+	fmt.Println("  --tag-match any|all    Hosts with any (default) or all of the --tag tags")
+	fmt.Println("  --control-socket PATH  Accept status, reload and remount <ip> on a Unix socket (start)")
+	fmt.Println("  --failure-threshold N  Log hosts failing N cycles in a row as ERROR (default 10)")
+	fmt.Println("  --webhook URL          POST a JSON alert when a host reaches the failure threshold")
+	fmt.Println("  --mount-base DIR       Resolve relative mount paths under DIR (default /root)")
+	fmt.Println("  --mount-perm MODE      Octal mode of created mount directories (default 755)")
+	fmt.Println("  --sshfs-path PATH      sshfs binary (default sshfs from PATH)")
+	fmt.Println("  --rclone-path PATH     rclone binary for backend=rclone hosts (default rclone from PATH)")
+	fmt.Println("  -o, --sshfs-opt OPT    sshfs -o option for every host, below its opts= (repeatable)")
+	fmt.Println("  --sshfs-extra-args ARGS  Extra arguments for every sshfs run, e.g. \"-o idmap=user\"")
+	fmt.Println("  --ssh-path PATH        ssh binary for the remote info probes")
+	fmt.Println("  --strict-host-key MODE ssh StrictHostKeyChecking: no (default), yes or accept-new")
+	fmt.Println("  --known-hosts FILE     ssh UserKnownHostsFile (per host: known_hosts=FILE)")
+	fmt.Println("  --ssh-config FILE      ssh config file for sshfs and ssh (-F); see alias=true")
+	fmt.Println("  --ping-count N         Probes per reachability check, any answer counts (default 2)")
+	fmt.Println("  --ping-method METHOD   icmp (default) or tcp, which connects to the SSH port")
+	fmt.Println("  --ssh-connect-timeout N  ssh ConnectTimeout for mounts and probes (default 5s)")
+	fmt.Println("  --uid, --gid ID        Local owner/group of mounted files (per host: uid=, gid=)")
+	fmt.Println("  --allow-other          Mount with allow_other (checks /etc/fuse.conf)")
+	fmt.Println("  --mount-as USER        Run sshfs as USER through sudo; the mounts are USER's (needs --allow-other)")
+	fmt.Println("  --fix-fuse-conf        Add user_allow_other to /etc/fuse.conf if missing")
+	fmt.Println("  --controlmaster        Reuse one ssh connection per host (sockets in " + sshfsmon.CONTROL_DIR + ")")
+	fmt.Println("  --remote-info=false    Skip the remote info ssh probes entirely")
+	fmt.Println("  --remote-fields LIST   Remote info to show, in order (default hostname,uptime,mac; also loadavg, diskfree)")
+	fmt.Println("  --parallel-remote-info=false  Run the remote info ssh probes one at a time")
+	fmt.Println("  --mount-timeout DUR   Give up on a hung sshfs after DUR (default 20s)")
+	fmt.Println("  --max-mount-time-warn DUR  Warn about mounts that succeed but take over DUR")
+	fmt.Println("  --mount-grace DUR      Never take a mount younger than DUR as stale (start)")
+	fmt.Println("  --remount-cooldown DUR Leave a stale mount in place for DUR after mounting it")
+	fmt.Println("  --max-per-destination N  Concurrent mounts per IP, for bastions with MaxSessions limits")
+	fmt.Println("  --conservative         Report stale mounts as STALE instead of force-unmounting them")
+	fmt.Println("  --deep-check           Write and read back a sentinel file through each mount; failing ones are STALE")
+	fmt.Println("  --allow-shadow         Mount even over mount directories that are not empty")
+	fmt.Println("  --safe-remount         Keep a stale mount until ssh to its host works again")
+	fmt.Println("  --delay-connect        Mount with delay_connect, also before hosts are reachable")
+	fmt.Println("  --post-mount-hook CMD  Run CMD after each new mount (SSHFS_HOST, SSHFS_MOUNT_PATH, ...)")
+	fmt.Println("  --post-unmount-hook CMD  Run CMD after a mount is torn down")
+	fmt.Println("  --on-all-up CMD        Run CMD when all hosts have become mounted (start)")
+	fmt.Println("  --on-all-down CMD      Run CMD when all hosts have become unreachable (start)")
+	fmt.Println("  --hook-timeout DUR     Kill hooks running longer than DUR (default 1m)")
+	fmt.Println("  --healthcheck-timeout DUR  Fail healthcheck= commands running longer than DUR (default 10s)")
+	fmt.Println("  --debug                Log why remote info probes fail (daemon log)")
+	fmt.Println("  --remount-after K      Mount a host only after K reachable cycles in a row (start, default 1)")
+	fmt.Println("  --stale-after M        Tear down a stale mount only after M failed cycles in a row (start, default 2)")
+	fmt.Println("  --cycle-deadline DUR   Move on when a cycle's mounts take longer (start, default 80% of --check-interval)")
+	fmt.Println("  --escalate-after N     Kill sshfs and remount a mount hung for N cycles (start, default 3, 0 off)")
+	fmt.Println("  --breaker-after N      Back off probing hosts failing N cycles in a row (start, 0 off)")
+	fmt.Println("  --breaker-max-skip M   Skip at most M cycles between probes of such a host (start, default 32)")
+	fmt.Println("  --idle-timeout DUR     Unmount mounts unused for DUR (start)")
+	fmt.Println("  --watch-mounts         Remount right away when a mount goes away (start)")
+	fmt.Println("  --pprof-addr ADDR      Serve pprof profiles on ADDR, e.g. :6060 for localhost:6060 (start)")
+	fmt.Println("  --http-tls-cert FILE   Serve the HTTP endpoints over HTTPS (with --http-tls-key FILE)")
+	fmt.Println("  --http-auth-token TOK  Require a bearer token on the HTTP endpoints")
+	fmt.Println("  --fail-on PCT|any      once exits 10 below PCT% hosts mounted, or on any failure")
+	fmt.Println("  --log-buffer N         Log lines kept in memory and shown by status (default 200)")
+	fmt.Println("  --log-repeat-interval DUR  Summarize repeated host log lines every DUR (default 10m)")
+	fmt.Println("  --output tui|jsonl     watch output; jsonl writes one JSON object per host per refresh")
+	fmt.Println("  --history-file FILE    Where start records per-cycle samples for history (\"\" off)")
+	fmt.Println("  --history-retention D  Keep history for D (default 168h)")
+	fmt.Println("  --since D              How far back history looks (default 24h)")
+	fmt.Println("  --bench-size MB        Size of the benchmark test file (default 4)")
+	fmt.Println("  --bench-unmount        Unmount the hosts benchmark mounted itself when done")
+	fmt.Println("  --force                Let init overwrite an existing hosts file")
+	fmt.Println("  --foreground           start: log to stdout and write no PID file, for systemd, runit or s6")
+	fmt.Println("  --allow-nonroot        Mount even when the mount paths look unwritable to a non-root user")
+	fmt.Println("  --io-stats             Count each mount's traffic from its sshfs process (Linux)")
+	fmt.Println("  --json                 JSON output: status (pid, uptime, hosts via --control-socket), once (per host)")
+	fmt.Println("  --summary-only         once: print only the summary and total time")
+	fmt.Println("  --check-only           once: reachability only, as the reach command")
+	fmt.Println("  --prune                Remove what prune lists; with start, on every hosts (re)load")
+	fmt.Println("  --no-clear-screen      Redraw watch in place, only changed lines (less flicker over ssh)")
+	fmt.Println("  --no-df                Skip disk usage (once, watch, dashboard)")
+	fmt.Println("  --no-mount             check-all only looks, never mounts")
+	fmt.Println("  --quiet                Suppress banners and stale endpoint notices (once, watch, dashboard)")
+	fmt.Println("  --user, --group NAME   User=/Group= of the generated unit (export-systemd)")
+	fmt.Println()
+	fmt.Println("Every option can also be set in the environment as SSHFS_<OPTION>, e.g.")
+	fmt.Println("SSHFS_CHECK_INTERVAL=10s (SSHFS_HOSTS_FILE for --hosts); options given on")
+	fmt.Println("the command line take precedence.")
+	fmt.Println()
+	fmt.Println("Send SIGHUP to a running daemon to reopen its log file and reload the hosts file, SIGUSR1 to run a cycle now.")
 	fmt.Println()
 	
-	hosts, _ := loadHosts()
+	hosts, hostsErr := loadHosts()
 	fmt.Println("Configuration:")
-	fmt.Printf("  Check interval: %ds\n", CHECK_INTERVAL)
-	fmt.Printf("  Log file: %s\n", LOG_FILE)
-	fmt.Printf("  PID file: %s\n", PID_FILE)
-	fmt.Printf("  Hosts file: %s\n", HOSTS_FILE)
+	fmt.Printf("  Check interval: %s\n", checkInterval)
+	fmt.Printf("  Log: %s\n", logDestination())
+	fmt.Printf("  PID file: %s\n", pidPath)
+	fmt.Printf("  Hosts file: %s\n", hostsSource)
+	fmt.Printf("  Mount base: %s\n", config.MountBase)
 	if len(hosts) > 0 {
 		var hostEntries []string
 		for _, host := range hosts {
@@ -859,17 +1726,45 @@ func showUsage() {
 		}
 		fmt.Printf("  Hosts (%d): %s\n", len(hosts), strings.Join(hostEntries, ", "))
 	} else {
-		fmt.Printf("  Hosts: Error loading from %s\n", HOSTS_FILE)
+		fmt.Printf("  Hosts: none, %v\n", hostsErr)
 	}
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		watchMode() // Default to watch mode
-		return
+	// Default to watch mode, with the flag defaults and SSHFS_* variables
+	// applied as for any other command
+	command, rest := "watch", []string(nil)
+	if len(os.Args) >= 2 {
+		command, rest = os.Args[1], os.Args[2:]
+	}
+	args := parseFlags(command, rest)
+	if command == "once" && checkOnly {
+		command = "reach"
+	}
+	if config.AllowOther {
+		checkFuseConf()
+	}
+	switch command {
+	case "start", "restart", "once", "watch", "dashboard", "mount", "benchmark":
+		if err := config.ResolveBinaries(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(EXIT_USAGE)
+		}
+	case "check-all":
+		if !noMount {
+			if err := config.ResolveBinaries(); err != nil {
+				fmt.Printf("SSHFS UNKNOWN - %v\n", err)
+				os.Exit(CHECK_UNKNOWN)
+			}
+		}
+	}
+	monitor = newMonitor()
+	if config.ControlMaster {
+		if err := monitor.PrepareControlDir(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot create %s, connection sharing disabled: %v\n", config.ControlDir, err)
+			monitor.Config.ControlMaster = false
+		}
 	}
-
-	command := os.Args[1]
 	
 	switch command {
 	case "start":
@@ -886,23 +1781,61 @@ func main() {
 		followLogs()
 	case "once":
 		start := time.Now()
-		fmt.Printf("SSHFS Auto-Mount Script (Go) - %s\n", time.Now().Format("Mon Jan 2 15:04:05 MST 2006"))
-		fmt.Println("Autodetecting and mounting SSHFS hosts in parallel...")
-		fmt.Println()
+		if !quiet && !jsonOutput && !summaryOnly {
+			fmt.Printf("SSHFS Auto-Mount Script (Go) - %s\n", time.Now().Format("Mon Jan 2 15:04:05 MST 2006"))
+			fmt.Println("Autodetecting and mounting SSHFS hosts in parallel...")
+			fmt.Println()
+		}
 		
 		hosts, err := loadHosts()
 		if err != nil {
-			log.Fatalf("Error loading hosts: %v", err)
+			fmt.Fprintf(os.Stderr, "Error loading hosts: %v\n", err)
+			os.Exit(EXIT_MOUNT_FAILED)
+		}
+		if !requireMountAccess(hosts) {
+			os.Exit(EXIT_MOUNT_FAILED)
 		}
 		
-		results := processHostsParallel(hosts)
+		results := monitor.ProcessHostsParallel(hosts)
 		totalTime := time.Since(start)
 		
-		printStats(results, totalTime)
+		switch {
+		case jsonOutput:
+			printResultsJSON(results, monitor.Status)
+		case summaryOnly:
+			printSummary(summarizeResults(results))
+			fmt.Printf("Total execution time: %.6fs\n", totalTime.Seconds())
+		default:
+			printStats(results, totalTime)
+		}
+		monitor.WaitHooks()
+		os.Exit(onceExitCode(results))
 	case "watch":
 		watchMode()
 	case "dashboard":
 		dashboardMode()
+	case "mount", "check":
+		os.Exit(singleHostMode(command, args))
+	case "export-systemd":
+		exportSystemd()
+	case "automap":
+		os.Exit(automapMode(args))
+	case "check-all":
+		os.Exit(checkAllMode())
+	case "validate":
+		os.Exit(validateMode())
+	case "trust":
+		os.Exit(trustMode())
+	case "history":
+		os.Exit(historyMode(args))
+	case "benchmark":
+		os.Exit(benchmarkMode(args))
+	case "init":
+		os.Exit(initMode())
+	case "prune":
+		os.Exit(pruneMode())
+	case "reach":
+		os.Exit(reachMode())
 	default:
 		showUsage()
 	}
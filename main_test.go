@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReopenLogAfterRotation(t *testing.T) {
+	dir := t.TempDir()
+	savedPath, savedDaemon := logPath, daemonMode
+	logPath = filepath.Join(dir, "sshfs-monitor.log")
+	daemonMode = true
+	t.Cleanup(func() {
+		logMu.Lock()
+		if logFile != nil {
+			logFile.Close()
+			logFile = nil
+		}
+		logMu.Unlock()
+		log.SetOutput(os.Stderr)
+		logPath, daemonMode = savedPath, savedDaemon
+	})
+
+	if err := initLogging(); err != nil {
+		t.Fatalf("initLogging: %v", err)
+	}
+	logMessage("before rotation")
+
+	// logrotate renames the file away, then runs postrotate
+	rotated := logPath + ".1"
+	if err := os.Rename(logPath, rotated); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopenLog(); err != nil {
+		t.Fatalf("reopenLog: %v", err)
+	}
+	logMessage("after rotation")
+
+	old, err := os.ReadFile(rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("no new log file: %v", err)
+	}
+	if !strings.Contains(string(old), "before rotation") || strings.Contains(string(old), "after rotation") {
+		t.Errorf("rotated log holds %q, want only the line from before", old)
+	}
+	if !strings.Contains(string(fresh), "after rotation") || strings.Contains(string(fresh), "before rotation") {
+		t.Errorf("new log holds %q, want only the line from after", fresh)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// REMOUNT_DEBOUNCE is how long the mount watcher waits after the first event
+// for a mount before remounting it. Later events in that window are folded in.
+const REMOUNT_DEBOUNCE = 2 * time.Second
+
+// mountWatcher uses inotify to notice mounts going away between cycles, for
+// instance when sshfs exits or the mount is unmounted, and remounts the host
+// right away. The periodic scan still runs; this only shortens the outage.
+//
+// A connection that hangs without unmounting produces no event and is left
+// to the periodic scan.
+type mountWatcher struct {
+	fd      int
+	state   *daemonState
+	mu      sync.Mutex
+	watches map[int32]string // watch descriptor -> watched path
+	pending map[string]bool  // mount paths with a remount queued or running
+	stopped bool             // run has given up reading events
+}
+
+func newMountWatcher(state *daemonState) (*mountWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("inotify unavailable: %v", err)
+	}
+	return &mountWatcher{
+		fd:      fd,
+		state:   state,
+		watches: make(map[int32]string),
+		pending: make(map[string]bool),
+	}, nil
+}
+
+// arm watches the mount point of every mounted host, and its parent
+// directory for the mount point being removed or renamed. Watches on an
+// unmounted file system disappear with it, so this runs after every cycle.
+func (w *mountWatcher) arm(results []sshfsmon.HostResult) {
+	for _, result := range results {
+		if !result.Mounted {
+			continue
+		}
+		path := result.Host.MountPath
+		w.add(filepath.Dir(path), syscall.IN_DELETE|syscall.IN_MOVED_FROM)
+		w.add(path, syscall.IN_UNMOUNT|syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF)
+	}
+}
+
+// armReaped watches the directories of reaped mounts for someone opening
+// them, to remount on demand. A reaped mount that cannot be watched is handed
+// back to the periodic cycle instead.
+func (w *mountWatcher) armReaped(paths []string) {
+	for _, path := range paths {
+		if !w.add(path, syscall.IN_OPEN|syscall.IN_ACCESS) {
+			w.state.unmarkReaped(path)
+		}
+	}
+}
+
+func (w *mountWatcher) add(path string, mask uint32) bool {
+	wd, err := syscall.InotifyAddWatch(w.fd, path, mask)
+	if err != nil {
+		logMessage(fmt.Sprintf("Cannot watch %s: %v", path, err))
+		return false
+	}
+	w.mu.Lock()
+	w.watches[int32(wd)] = path
+	w.mu.Unlock()
+	return true
+}
+
+// onDemand reports whether the watcher is still there to remount reaped
+// mounts when they are accessed.
+func (w *mountWatcher) onDemand() bool {
+	if w == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !w.stopped
+}
+
+// run reads inotify events until the descriptor fails.
+func (w *mountWatcher) run() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			logMessage(fmt.Sprintf("Mount watcher stopped, falling back to polling: %v", err))
+			w.mu.Lock()
+			w.stopped = true
+			w.mu.Unlock()
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+			offset = nameStart + int(event.Len)
+
+			w.mu.Lock()
+			path, known := w.watches[event.Wd]
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.watches, event.Wd)
+			}
+			w.mu.Unlock()
+			if !known {
+				continue
+			}
+			if name != "" {
+				path = filepath.Join(path, name)
+			}
+			w.trigger(path, event.Mask)
+		}
+	}
+}
+
+// trigger schedules a remount of the host mounted at path, unless one is
+// already queued or running. A reaped mount is only remounted when its
+// directory is accessed, not for going away, which the reaper did itself.
+func (w *mountWatcher) trigger(path string, mask uint32) {
+	access := mask&(syscall.IN_OPEN|syscall.IN_ACCESS) != 0
+	if access != w.state.isReaped(path) {
+		return
+	}
+
+	var targets []sshfsmon.Host
+	for _, host := range w.state.currentHosts() {
+		if host.MountPath == path {
+			targets = append(targets, host)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	if w.pending[path] {
+		w.mu.Unlock()
+		return
+	}
+	w.pending[path] = true
+	w.mu.Unlock()
+
+	if access {
+		logMessage(fmt.Sprintf("Reaped mount %s accessed, remounting", path))
+	} else {
+		logMessage(fmt.Sprintf("Mount %s went away, remounting", path))
+	}
+	time.AfterFunc(REMOUNT_DEBOUNCE, func() {
+		results := w.state.refresh(targets)
+		w.arm(results)
+		w.mu.Lock()
+		delete(w.pending, path)
+		w.mu.Unlock()
+	})
+}
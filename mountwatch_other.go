@@ -0,0 +1,24 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// mountWatcher needs inotify; elsewhere --watch-mounts falls back to polling.
+type mountWatcher struct{}
+
+func newMountWatcher(state *daemonState) (*mountWatcher, error) {
+	return nil, fmt.Errorf("mount watching needs inotify, which this platform lacks")
+}
+
+func (w *mountWatcher) arm(results []sshfsmon.HostResult) {}
+
+func (w *mountWatcher) armReaped(paths []string) {}
+
+func (w *mountWatcher) onDemand() bool { return false }
+
+func (w *mountWatcher) run() {}
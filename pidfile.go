@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// STOP_GRACE is how long stop waits after SIGTERM before sending SIGKILL.
+const STOP_GRACE = 2 * time.Second
+
+// errPidLocked is returned by pidFS.Lock when another daemon holds the lock.
+var errPidLocked = errors.New("PID file locked")
+
+// pidFS is what the daemon lifecycle needs of the file system for its PID
+// file, so tests can run it against a fake. Whether a daemon runs is told by
+// the file's lock, never by its content: a daemon holds the lock from before
+// it writes its PID until after it removes the file.
+type pidFS interface {
+	Stat(path string) (os.FileInfo, error)
+	ReadFile(path string) ([]byte, error)
+	// Lock opens path, creating it if create is set, and takes an
+	// exclusive lock on it without waiting; it fails with errPidLocked
+	// when the lock is held. The locked file is the one path names.
+	Lock(path string, create bool) (lockedFile, error)
+}
+
+// lockedFile is a PID file held under its lock.
+type lockedFile interface {
+	// Replace makes data the file's whole content, on disk.
+	Replace(data []byte) error
+	// Remove deletes the file, if its path still names it.
+	Remove() error
+	// Unlock drops the lock and closes the file.
+	Unlock() error
+}
+
+// processSignaler checks on and signals the process a PID file names.
+type processSignaler interface {
+	Alive(pid int) bool
+	Signal(pid int, sig syscall.Signal) error
+}
+
+// osPidFS is pidFS on the real file system, locking with flock.
+type osPidFS struct{}
+
+func (osPidFS) Stat(path string) (os.FileInfo, error) { return os.Stat(path) }
+func (osPidFS) ReadFile(path string) ([]byte, error)  { return os.ReadFile(path) }
+
+func (osPidFS) Lock(path string, create bool) (lockedFile, error) {
+	flags := os.O_RDWR
+	if create {
+		flags |= os.O_CREATE
+	}
+	for {
+		f, err := os.OpenFile(path, flags, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			f.Close()
+			if err == syscall.EWOULDBLOCK {
+				return nil, errPidLocked
+			}
+			return nil, err
+		}
+		// The holder before us may have removed the file between our open
+		// and our lock; a lock on a file path no longer names guards
+		// nothing, so start over on whatever path names now
+		locked := flockFile{f, path}
+		if locked.current() {
+			return locked, nil
+		}
+		locked.Unlock()
+	}
+}
+
+type flockFile struct {
+	f    *os.File
+	path string
+}
+
+// current reports whether path still names the locked file.
+func (l flockFile) current() bool {
+	held, err := l.f.Stat()
+	if err != nil {
+		return false
+	}
+	named, err := os.Stat(l.path)
+	return err == nil && os.SameFile(held, named)
+}
+
+func (l flockFile) Replace(data []byte) error {
+	if err := l.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.f.WriteAt(data, 0); err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+func (l flockFile) Remove() error {
+	if !l.current() {
+		return nil
+	}
+	return os.Remove(l.path)
+}
+
+func (l flockFile) Unlock() error {
+	syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+	return l.f.Close()
+}
+
+// osProcesses signals real processes.
+type osProcesses struct{}
+
+// Alive reports whether pid exists. EPERM means it does but belongs to
+// someone else.
+func (osProcesses) Alive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+func (osProcesses) Signal(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
+
+// heldPidFile is the daemon's PID file while it runs.
+type heldPidFile struct {
+	lock lockedFile
+}
+
+// acquirePidFile locks the PID file at path and writes pid to it. The lock
+// lives as long as the daemon holds the file, so two daemons started at the
+// same moment cannot both get past this point, and a PID file whose lock is
+// free is stale by definition.
+func acquirePidFile(fsys pidFS, path string, pid int) (*heldPidFile, error) {
+	lock, err := fsys.Lock(path, true)
+	if err == errPidLocked {
+		pidData, _ := fsys.ReadFile(path)
+		return nil, fmt.Errorf("SSHFS monitor already running (PID: %s)", strings.TrimSpace(string(pidData)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock PID file: %v", err)
+	}
+	if err := lock.Replace([]byte(strconv.Itoa(pid))); err != nil {
+		lock.Remove()
+		lock.Unlock()
+		return nil, fmt.Errorf("failed to write PID file: %v", err)
+	}
+	return &heldPidFile{lock: lock}, nil
+}
+
+// releasePidFile removes the PID file before dropping the lock, so a new
+// daemon never sees our PID in a file it managed to lock. A nil file, as
+// in the foreground, is a no-op.
+func releasePidFile(p *heldPidFile) {
+	if p == nil {
+		return
+	}
+	p.lock.Remove()
+	p.lock.Unlock()
+}
+
+// pidState is what a PID file says about the daemon.
+type pidState int
+
+const (
+	daemonNotRunning pidState = iota // no PID file
+	daemonStale                      // a PID file nobody holds the lock of
+	daemonRunning
+)
+
+// pidFileInfo is the outcome of checking a PID file.
+type pidFileInfo struct {
+	State pidState
+	PID   int         // 0 while a starting daemon has not written it yet
+	Info  os.FileInfo // the PID file, for the daemon's start time
+}
+
+// checkDaemon tells whether a daemon holds the PID file at path. A file
+// whose lock is free is stale and removed; one whose lock is held is kept
+// whatever it says, as a daemon may be just starting and not have written
+// its PID yet.
+func checkDaemon(fsys pidFS, path string) (pidFileInfo, error) {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return pidFileInfo{State: daemonNotRunning}, nil
+	}
+	lock, err := fsys.Lock(path, false)
+	if os.IsNotExist(err) {
+		return pidFileInfo{State: daemonNotRunning}, nil
+	}
+	if err != nil && err != errPidLocked {
+		return pidFileInfo{}, fmt.Errorf("error locking PID file: %v", err)
+	}
+	pidData, _ := fsys.ReadFile(path)
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(pidData)))
+	if err == errPidLocked {
+		return pidFileInfo{State: daemonRunning, PID: pid, Info: info}, nil
+	}
+	lock.Remove()
+	lock.Unlock()
+	return pidFileInfo{State: daemonStale, PID: pid}, nil
+}
+
+// stopProcess stops the daemon holding the PID file at path: SIGTERM, then
+// SIGKILL if it is still alive after grace. It reports what it did to out.
+func stopProcess(fsys pidFS, procs processSignaler, path string, grace time.Duration, out io.Writer) error {
+	daemon, err := checkDaemon(fsys, path)
+	if err != nil {
+		return err
+	}
+	if daemon.State != daemonRunning {
+		fmt.Fprintln(out, "SSHFS monitor not running")
+		return nil
+	}
+	if daemon.PID <= 0 {
+		return fmt.Errorf("SSHFS monitor is starting and has not written its PID yet; try again")
+	}
+
+	fmt.Fprintf(out, "Stopping SSHFS monitor (PID: %d)...\n", daemon.PID)
+	if err := procs.Signal(daemon.PID, syscall.SIGTERM); err != nil {
+		return fmt.Errorf("error stopping daemon: %v", err)
+	}
+
+	// Wait for graceful shutdown
+	time.Sleep(grace)
+
+	// Force kill if still running
+	if procs.Alive(daemon.PID) {
+		procs.Signal(daemon.PID, syscall.SIGKILL)
+		fmt.Fprintln(out, "Force killed SSHFS monitor")
+	}
+
+	// A stopped daemon removes its own PID file; a killed one leaves it,
+	// so clear it, unless its lock is held again by then
+	if lock, err := fsys.Lock(path, false); err == nil {
+		lock.Remove()
+		lock.Unlock()
+	}
+	fmt.Fprintln(out, "SSHFS monitor stopped")
+	return nil
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeFS is an in-memory pidFS. Locks belong to files, as flock's do, and
+// every removal and unlock is logged so tests can check the order of steps.
+type fakeFS struct {
+	mu    sync.Mutex
+	files map[string]*fakeFile
+	ops   []string
+}
+
+type fakeFile struct {
+	data   []byte
+	locked bool
+}
+
+func newFakeFS() *fakeFS {
+	return &fakeFS{files: map[string]*fakeFile{}}
+}
+
+// put creates a PID file holding content, with its lock held if locked.
+func (f *fakeFS) put(path, content string, locked bool) {
+	f.files[path] = &fakeFile{data: []byte(content), locked: locked}
+}
+
+// content returns the file at path and whether there is one.
+func (f *fakeFS) content(path string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, ok := f.files[path]
+	if !ok {
+		return "", false
+	}
+	return string(file.data), true
+}
+
+func (f *fakeFS) Stat(path string) (os.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.files[path]; !ok {
+		return nil, os.ErrNotExist
+	}
+	return fakeInfo{filepath.Base(path)}, nil
+}
+
+func (f *fakeFS) ReadFile(path string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, ok := f.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return file.data, nil
+}
+
+func (f *fakeFS) Lock(path string, create bool) (lockedFile, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, ok := f.files[path]
+	if !ok {
+		if !create {
+			return nil, os.ErrNotExist
+		}
+		file = &fakeFile{}
+		f.files[path] = file
+	}
+	if file.locked {
+		return nil, errPidLocked
+	}
+	file.locked = true
+	return &fakeLock{fs: f, path: path, file: file}, nil
+}
+
+type fakeLock struct {
+	fs   *fakeFS
+	path string
+	file *fakeFile
+}
+
+func (l *fakeLock) Replace(data []byte) error {
+	l.fs.mu.Lock()
+	defer l.fs.mu.Unlock()
+	l.file.data = append([]byte(nil), data...)
+	return nil
+}
+
+func (l *fakeLock) Remove() error {
+	l.fs.mu.Lock()
+	defer l.fs.mu.Unlock()
+	l.fs.ops = append(l.fs.ops, "remove "+l.path)
+	if l.fs.files[l.path] == l.file {
+		delete(l.fs.files, l.path)
+	}
+	return nil
+}
+
+func (l *fakeLock) Unlock() error {
+	l.fs.mu.Lock()
+	defer l.fs.mu.Unlock()
+	l.fs.ops = append(l.fs.ops, "unlock "+l.path)
+	l.file.locked = false
+	return nil
+}
+
+type fakeInfo struct{ name string }
+
+func (i fakeInfo) Name() string       { return i.name }
+func (i fakeInfo) Size() int64        { return 0 }
+func (i fakeInfo) Mode() os.FileMode  { return 0644 }
+func (i fakeInfo) ModTime() time.Time { return time.Time{} }
+func (i fakeInfo) IsDir() bool        { return false }
+func (i fakeInfo) Sys() interface{}   { return nil }
+
+// fakeProcesses is a processSignaler over a set of live PIDs, each of them
+// a daemon holding the PID file at path of fs. Processes in ignoreTerm
+// survive SIGTERM. One that exits on SIGTERM removes its PID file, as the
+// daemon does; on SIGKILL only the lock goes, as the kernel drops it.
+type fakeProcesses struct {
+	fs         *fakeFS
+	path       string
+	alive      map[int]bool
+	ignoreTerm map[int]bool
+	signals    []syscall.Signal
+}
+
+func (p *fakeProcesses) Alive(pid int) bool { return p.alive[pid] }
+
+func (p *fakeProcesses) Signal(pid int, sig syscall.Signal) error {
+	if !p.alive[pid] {
+		return syscall.ESRCH
+	}
+	p.signals = append(p.signals, sig)
+	if sig != syscall.SIGKILL && p.ignoreTerm[pid] {
+		return nil
+	}
+	delete(p.alive, pid)
+	p.fs.mu.Lock()
+	defer p.fs.mu.Unlock()
+	if file, ok := p.fs.files[p.path]; ok {
+		file.locked = false
+		if sig != syscall.SIGKILL {
+			delete(p.fs.files, p.path)
+		}
+	}
+	return nil
+}
+
+const testPidPath = "/run/test.pid"
+
+func TestCheckDaemon(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   *string
+		locked    bool
+		wantState pidState
+		wantPID   int
+		wantKept  bool
+	}{
+		{name: "no PID file", wantState: daemonNotRunning},
+		{name: "stale PID file", content: strPtr("4242\n"), wantState: daemonStale, wantPID: 4242},
+		{name: "empty PID file", content: strPtr(""), wantState: daemonStale},
+		{name: "empty PID file of a starting daemon", content: strPtr(""), locked: true, wantState: daemonRunning, wantKept: true},
+		{name: "garbage PID file", content: strPtr("not-a-pid"), wantState: daemonStale},
+		{name: "live process", content: strPtr("4242\n"), locked: true, wantState: daemonRunning, wantPID: 4242, wantKept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := newFakeFS()
+			if tt.content != nil {
+				fsys.put(testPidPath, *tt.content, tt.locked)
+			}
+
+			daemon, err := checkDaemon(fsys, testPidPath)
+			if err != nil {
+				t.Fatalf("checkDaemon: %v", err)
+			}
+			if daemon.State != tt.wantState || daemon.PID != tt.wantPID {
+				t.Errorf("got state %d PID %d, want state %d PID %d", daemon.State, daemon.PID, tt.wantState, tt.wantPID)
+			}
+			_, kept := fsys.content(testPidPath)
+			if tt.content != nil && kept != tt.wantKept {
+				t.Errorf("PID file kept = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestStopProcess(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     *string
+		alive       bool
+		ignoreTerm  bool
+		wantSignals []syscall.Signal
+		wantOutput  []string
+	}{
+		{name: "no PID file", wantOutput: []string{"not running"}},
+		{name: "stale PID file", content: strPtr("4242"), wantOutput: []string{"not running"}},
+		{
+			name: "live process", content: strPtr("4242"), alive: true,
+			wantSignals: []syscall.Signal{syscall.SIGTERM},
+			wantOutput:  []string{"Stopping SSHFS monitor (PID: 4242)", "stopped"},
+		},
+		{
+			name: "process ignoring SIGTERM", content: strPtr("4242"), alive: true, ignoreTerm: true,
+			wantSignals: []syscall.Signal{syscall.SIGTERM, syscall.SIGKILL},
+			wantOutput:  []string{"Force killed", "stopped"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := newFakeFS()
+			if tt.content != nil {
+				fsys.put(testPidPath, *tt.content, tt.alive)
+			}
+			procs := &fakeProcesses{fs: fsys, path: testPidPath, alive: map[int]bool{}, ignoreTerm: map[int]bool{}}
+			if tt.alive {
+				procs.alive[4242] = true
+				procs.ignoreTerm[4242] = tt.ignoreTerm
+			}
+
+			var out bytes.Buffer
+			if err := stopProcess(fsys, procs, testPidPath, 0, &out); err != nil {
+				t.Fatalf("stopProcess: %v", err)
+			}
+			if len(procs.signals) != len(tt.wantSignals) {
+				t.Fatalf("signals %v, want %v", procs.signals, tt.wantSignals)
+			}
+			for i, sig := range tt.wantSignals {
+				if procs.signals[i] != sig {
+					t.Errorf("signal %d = %v, want %v", i, procs.signals[i], sig)
+				}
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output %q lacks %q", out.String(), want)
+				}
+			}
+			if _, ok := fsys.content(testPidPath); ok {
+				t.Error("PID file left behind")
+			}
+			if len(procs.alive) != 0 {
+				t.Errorf("processes still alive: %v", procs.alive)
+			}
+		})
+	}
+}
+
+func TestAcquirePidFile(t *testing.T) {
+	fsys := newFakeFS()
+	held, err := acquirePidFile(fsys, testPidPath, 100)
+	if err != nil {
+		t.Fatalf("acquirePidFile: %v", err)
+	}
+	if got, _ := fsys.content(testPidPath); got != "100" {
+		t.Errorf("PID file holds %q, want 100", got)
+	}
+
+	// A second daemon is turned away and told who runs
+	if _, err := acquirePidFile(fsys, testPidPath, 200); err == nil || !strings.Contains(err.Error(), "PID: 100") {
+		t.Errorf("second acquire: got %v, want already running (PID: 100)", err)
+	}
+
+	// The file goes before the lock, so nobody locks a file naming us
+	fsys.ops = nil
+	releasePidFile(held)
+	if want := []string{"remove " + testPidPath, "unlock " + testPidPath}; strings.Join(fsys.ops, ",") != strings.Join(want, ",") {
+		t.Errorf("release ops %v, want %v", fsys.ops, want)
+	}
+	if _, err := acquirePidFile(fsys, testPidPath, 200); err != nil {
+		t.Errorf("acquire after release: %v", err)
+	}
+
+	releasePidFile(nil)
+}
+
+func TestAcquirePidFileStaleContent(t *testing.T) {
+	// A PID file left by a crashed daemon has no lock holder, so it is
+	// simply taken over
+	fsys := newFakeFS()
+	fsys.put(testPidPath, "123456789", false)
+	if _, err := acquirePidFile(fsys, testPidPath, 100); err != nil {
+		t.Fatalf("acquirePidFile: %v", err)
+	}
+	if got, _ := fsys.content(testPidPath); got != "100" {
+		t.Errorf("PID file holds %q, want 100", got)
+	}
+}
+
+func TestCheckBeforePidWritten(t *testing.T) {
+	// Real flock: status runs while a starting daemon holds the lock but
+	// has not written its PID yet
+	path := filepath.Join(t.TempDir(), "sshfs-monitor.pid")
+	lock, err := osPidFS{}.Lock(path, true)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	daemon, err := checkDaemon(osPidFS{}, path)
+	if err != nil {
+		t.Fatalf("checkDaemon: %v", err)
+	}
+	if daemon.State != daemonRunning || daemon.PID != 0 {
+		t.Errorf("got state %d PID %d, want running with no PID yet", daemon.State, daemon.PID)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("PID file of the starting daemon removed: %v", err)
+	}
+	var out bytes.Buffer
+	if err := stopProcess(osPidFS{}, &fakeProcesses{}, path, 0, &out); err == nil {
+		t.Error("stop must refuse a daemon whose PID is not written yet")
+	}
+
+	// So a second daemon still finds the lock taken
+	if _, err := acquirePidFile(osPidFS{}, path, 200); err == nil {
+		t.Fatal("second daemon started next to the first")
+	}
+
+	if err := lock.Replace([]byte("100")); err != nil {
+		t.Fatal(err)
+	}
+	if daemon, _ := checkDaemon(osPidFS{}, path); daemon.State != daemonRunning || daemon.PID != 100 {
+		t.Errorf("after the PID is written: state %d PID %d, want running 100", daemon.State, daemon.PID)
+	}
+	releasePidFile(&heldPidFile{lock: lock})
+	if daemon, _ := checkDaemon(osPidFS{}, path); daemon.State != daemonNotRunning {
+		t.Errorf("after release: state %d, want not running", daemon.State)
+	}
+}
+
+func TestReleaseKeepsReplacedFile(t *testing.T) {
+	// Real files: a daemon whose PID file was replaced under it must not
+	// remove the replacement on the way out
+	path := filepath.Join(t.TempDir(), "sshfs-monitor.pid")
+	held, err := acquirePidFile(osPidFS{}, path, 100)
+	if err != nil {
+		t.Fatalf("acquirePidFile: %v", err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("200"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	releasePidFile(held)
+	if data, err := os.ReadFile(path); err != nil || string(data) != "200" {
+		t.Errorf("replacement PID file: %q, %v; want it kept", data, err)
+	}
+}
+
+func TestConcurrentStart(t *testing.T) {
+	// Real flock: locks belong to open files, so goroutines of one
+	// process race like separate daemons do
+	path := filepath.Join(t.TempDir(), "sshfs-monitor.pid")
+	const starters = 8
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	held := make(chan *heldPidFile, starters)
+	errs := make(chan error, starters)
+	for i := 0; i < starters; i++ {
+		wg.Add(1)
+		go func(pid int) {
+			defer wg.Done()
+			<-start
+			p, err := acquirePidFile(osPidFS{}, path, pid)
+			if err != nil {
+				errs <- err
+				return
+			}
+			held <- p
+		}(1000 + i)
+	}
+	close(start)
+	wg.Wait()
+	close(held)
+	close(errs)
+
+	if len(held) != 1 {
+		t.Fatalf("%d starters got the PID file, want 1", len(held))
+	}
+	for err := range errs {
+		if !strings.Contains(err.Error(), "already running") {
+			t.Errorf("losing starter: %v", err)
+		}
+	}
+	winner := <-held
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "10") {
+		t.Errorf("PID file holds %q", data)
+	}
+	releasePidFile(winner)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("PID file still there after release: %v", err)
+	}
+}
+
+func strPtr(s string) *string { return &s }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// MAX_LISTED_PATHS caps the mount paths named in the privilege check's
+// message.
+const MAX_LISTED_PATHS = 3
+
+// mountAccessError is the early privilege check of the commands that mount.
+// For a user other than root it returns an error naming the mount paths
+// that user cannot mount on, as with the default /root mount base, so one
+// clear message replaces a failed mount per host. Root passes, and so does
+// anyone who can write to every mount path.
+func mountAccessError(hosts []sshfsmon.Host) error {
+	uid := os.Geteuid()
+	if uid == 0 {
+		return nil
+	}
+	blocked := unwritableMountPaths(hosts)
+	if len(blocked) == 0 {
+		return nil
+	}
+	listed := blocked
+	if len(listed) > MAX_LISTED_PATHS {
+		listed = listed[:MAX_LISTED_PATHS]
+	}
+	paths := strings.Join(listed, ", ")
+	if more := len(blocked) - len(listed); more > 0 {
+		paths += fmt.Sprintf(" and %d more", more)
+	}
+	name := fmt.Sprintf("uid %d", uid)
+	if u, err := user.LookupId(fmt.Sprint(uid)); err == nil {
+		name = fmt.Sprintf("%s (uid %d)", u.Username, uid)
+	}
+	return fmt.Errorf("running as %s, which cannot create or mount on %s; run as root, or use a --mount-base you can write to, e.g. --mount-base $HOME/mnt (--allow-nonroot tries anyway)", name, paths)
+}
+
+// requireMountAccess prints mountAccessError's message and reports whether
+// the command may go on: only with --allow-nonroot, for rootless setups the
+// check cannot see through, and then the message is a warning.
+func requireMountAccess(hosts []sshfsmon.Host) bool {
+	err := mountAccessError(hosts)
+	if err == nil {
+		return true
+	}
+	if allowNonroot {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return false
+}
+
+// unwritableMountPaths returns the mount paths that are not writable, or
+// whose nearest existing parent is not, so they cannot be created.
+func unwritableMountPaths(hosts []sshfsmon.Host) []string {
+	var blocked []string
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		if seen[host.MountPath] {
+			continue
+		}
+		seen[host.MountPath] = true
+		path := host.MountPath
+		for {
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				break
+			}
+			parent := filepath.Dir(path)
+			if parent == path {
+				break
+			}
+			path = parent
+		}
+		if err := syscall.Access(path, 2); err != nil { // W_OK
+			blocked = append(blocked, host.MountPath)
+		}
+	}
+	return blocked
+}
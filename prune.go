@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// pruneMode lists the directories under the mount base left behind by hosts
+// no longer in the hosts file, see orphanedMountDirs, and with --prune
+// removes them. It returns non-zero if a directory could not be removed.
+func pruneMode() int {
+	// Hosts --tag leaves out still own their directories
+	hosts, err := loadAllHosts()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading hosts: %v\n", err)
+		return EXIT_MOUNT_FAILED
+	}
+	dirs, err := orphanedMountDirs(hosts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return EXIT_MOUNT_FAILED
+	}
+	if len(dirs) == 0 {
+		fmt.Printf("No orphaned mount directories in %s\n", config.MountBase)
+		return EXIT_MOUNTED
+	}
+
+	exitCode := EXIT_MOUNTED
+	for _, dir := range dirs {
+		if !pruneDirs {
+			fmt.Printf("Would remove %s\n", dir)
+			continue
+		}
+		if err := os.Remove(dir); err != nil {
+			fmt.Printf("%sFAILED%s to remove %s: %v\n", colorRed, colorReset, dir, err)
+			exitCode = EXIT_MOUNT_FAILED
+			continue
+		}
+		fmt.Printf("Removed %s\n", dir)
+	}
+	if !pruneDirs {
+		fmt.Printf("%d orphaned mount director(ies); run prune --prune to remove them\n", len(dirs))
+	}
+	return exitCode
+}
+
+// sweepMountDirs is the daemon's prune: with --prune it removes the orphaned
+// mount directories after the hosts were (re)loaded, logging each. hosts are
+// all hosts of the hosts source, not only those --tag selects.
+func sweepMountDirs(hosts []sshfsmon.Host) {
+	if !pruneDirs {
+		return
+	}
+	dirs, err := orphanedMountDirs(hosts)
+	if err != nil {
+		logMessage(fmt.Sprintf("WARN: Cannot prune mount directories: %v", err))
+		return
+	}
+	for _, dir := range dirs {
+		if err := os.Remove(dir); err != nil {
+			logMessage(fmt.Sprintf("WARN: Failed to prune mount directory %s: %v", dir, err))
+			continue
+		}
+		logMessage(fmt.Sprintf("Pruned orphaned mount directory %s", dir))
+	}
+}
+
+// orphanedMountDirs returns the directories directly under the mount base
+// that look like mount points of removed hosts: empty, not mounted, and
+// neither the mount path of a host, ours or another daemon's, nor a parent
+// of one. A directory with files in it may be a broken mount shadowing its
+// content and is never returned, nor are hidden directories.
+func orphanedMountDirs(hosts []sshfsmon.Host) ([]string, error) {
+	entries, err := os.ReadDir(config.MountBase)
+	if err != nil {
+		return nil, err
+	}
+
+	var used []string
+	for _, host := range hosts {
+		used = append(used, host.MountPath)
+	}
+	others, err := registeredMountPaths()
+	if err != nil {
+		return nil, err
+	}
+	used = append(used, others...)
+
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		dir := filepath.Join(config.MountBase, entry.Name())
+		if mountPathUsed(dir, used) || sshfsmon.IsMountPoint(dir) || !emptyDir(dir) {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// mountPathUsed reports whether dir is one of the mount paths or contains one.
+func mountPathUsed(dir string, paths []string) bool {
+	for _, path := range paths {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// emptyDir reports whether dir can be read and has no entries. A dead mount
+// that cannot be read counts as not empty.
+func emptyDir(dir string) bool {
+	f, err := os.Open(dir)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, err = f.Readdirnames(1)
+	return err == io.EOF
+}
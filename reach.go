@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// reachMode is the reach command and once --check-only: a sweep of every
+// host's ping and SSH port that mounts, clears and probes nothing, cheap
+// enough to run far more often than mounts need reconciling. It returns
+// EXIT_UNREACHABLE if any host is down or refuses ssh.
+func reachMode() int {
+	start := time.Now()
+	hosts, err := loadHosts()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading hosts: %v\n", err)
+		return EXIT_MOUNT_FAILED
+	}
+	results := monitor.CheckHostsParallel(hosts)
+
+	up := 0
+	for _, result := range results {
+		if reachStatus(result) == "UP" {
+			up++
+		}
+	}
+	if jsonOutput {
+		printResultsJSON(results, reachStatus)
+	} else {
+		printReach(results, up, time.Since(start))
+	}
+	if up < len(results) {
+		return EXIT_UNREACHABLE
+	}
+	return EXIT_MOUNTED
+}
+
+// reachStatus classifies a reachability-only result as UP, SSH-DOWN or
+// OFFLINE. Mounts are not looked at, so UP says nothing about them.
+func reachStatus(result sshfsmon.HostResult) string {
+	switch {
+	case !result.Reachable:
+		return "OFFLINE"
+	case !result.SSHReachable:
+		return "SSH-DOWN"
+	}
+	return "UP"
+}
+
+func printReach(results []sshfsmon.HostResult, up int, totalTime time.Duration) {
+	fmt.Println("==================== SSHFS REACHABILITY ONLY ===================")
+	fmt.Println("Nothing was mounted and mounts were not checked.")
+	fmt.Printf("%-18s %-10s %-12s %-15s\n", "HOST", "STATUS", "PING (ms)", "CHECK TIME")
+	fmt.Println("------------------------------------------------------------------")
+	for _, result := range results {
+		status := reachStatus(result)
+		color := colorGreen
+		pingTimeStr := "N/A"
+		if result.Reachable {
+			pingTimeStr = fmt.Sprintf("%.3f", float64(result.PingTime.Nanoseconds())/1e6)
+		}
+		switch status {
+		case "SSH-DOWN":
+			color = colorYellow
+		case "OFFLINE":
+			color = colorRed
+		}
+		fmt.Printf("%-18s %s%-10s%s %-12s %-15s\n", displayName(result.Host),
+			color, status, colorReset, pingTimeStr, fmt.Sprintf("%.6fs", result.CheckTime.Seconds()))
+	}
+	fmt.Println("=================================================================")
+	fmt.Printf("%d/%d hosts up (reachability only, mounts not checked)\n", up, len(results))
+	fmt.Printf("Total execution time: %.6fs\n", totalTime.Seconds())
+}
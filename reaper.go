@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// idleReaper unmounts mounts nobody has used for longer than timeout, so
+// they stop holding an ssh connection open.
+//
+// Use is measured with the I/O counters of the sshfs process serving the
+// mount: every access to the mount goes through it. The counters are sampled
+// right after each cycle, once the daemon's own checks are done, and compared
+// at the start of the next one. A mount whose sshfs process cannot be found
+// counts as in use.
+type idleReaper struct {
+	timeout time.Duration
+	mounts  map[string]*mountActivity // keyed by mount path
+}
+
+type mountActivity struct {
+	host       sshfsmon.Host
+	io         string // sshfs I/O counters after the daemon's last own access
+	lastActive time.Time
+}
+
+func newIdleReaper(timeout time.Duration) *idleReaper {
+	return &idleReaper{timeout: timeout, mounts: make(map[string]*mountActivity)}
+}
+
+// snapshot records the I/O counters of every mounted host.
+func (r *idleReaper) snapshot(results []sshfsmon.HostResult) {
+	mounted := make(map[string]bool)
+	for _, result := range results {
+		if !result.Mounted {
+			continue
+		}
+		path := result.Host.MountPath
+		mounted[path] = true
+		activity := r.mounts[path]
+		if activity == nil {
+			activity = &mountActivity{lastActive: time.Now()}
+			r.mounts[path] = activity
+		}
+		activity.host = result.Host
+		activity.io = sshfsIO(path)
+	}
+	for path := range r.mounts {
+		if !mounted[path] {
+			delete(r.mounts, path)
+		}
+	}
+}
+
+// reapIdle unmounts the mounts that saw no use for the timeout. Reaped mounts
+// are marked in state first, so neither the cycle nor the mount watcher
+// remounts them straight away.
+func (r *idleReaper) reapIdle(state *daemonState) {
+	now := time.Now()
+	for path, activity := range r.mounts {
+		if io := sshfsIO(path); io == "" || io != activity.io {
+			activity.lastActive = now
+			continue
+		}
+		idle := now.Sub(activity.lastActive)
+		if idle < r.timeout {
+			continue
+		}
+
+		state.markReaped(path)
+		if err := monitor.Unmount(path); err != nil {
+			logMessage(fmt.Sprintf("Could not reap idle mount %s: %v", path, err))
+			state.unmarkReaped(path)
+			activity.lastActive = now
+			continue
+		}
+		logMessage(fmt.Sprintf("Unmounted %s after %s idle", path, idle.Round(time.Second)))
+		monitor.RunHook(config.PostUnmountHook, "post-unmount", activity.host)
+		delete(r.mounts, path)
+	}
+}
+
+// sshfsIO returns the I/O counters of the sshfs process serving mountPath,
+// or "" if there is none or /proc is unavailable.
+func sshfsIO(mountPath string) string {
+	counters, ok := sshfsCounters(mountPath)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("rchar: %d wchar: %d", counters.read, counters.written)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// MOUNT_REGISTRY records which daemon manages which mount paths, so two
+// daemons with different hosts files do not fight over the same mount. It
+// maps each daemon's PID to its mount paths.
+const MOUNT_REGISTRY = "/var/run/sshfs-monitor-mounts.json"
+
+// updateRegistry runs update on the registry under an exclusive lock,
+// dropping the entries of daemons that are no longer running, and writes
+// the result back.
+func updateRegistry(update func(registry map[string][]string) error) error {
+	f, err := os.OpenFile(registryPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open mount registry: %v", err)
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock mount registry: %v", err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	registry := make(map[string][]string)
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("failed to read mount registry: %v", err)
+	}
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := json.Unmarshal(data, &registry); err != nil {
+			return fmt.Errorf("failed to parse mount registry %s: %v", registryPath, err)
+		}
+	}
+	for pid := range registry {
+		if !processAlive(pid) {
+			delete(registry, pid)
+		}
+	}
+
+	if err := update(registry); err != nil {
+		return err
+	}
+
+	data, err = json.MarshalIndent(registry, "", "  ")
+	if err == nil {
+		err = f.Truncate(0)
+	}
+	if err == nil {
+		_, err = f.WriteAt(append(data, '\n'), 0)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write mount registry: %v", err)
+	}
+	return nil
+}
+
+func processAlive(pid string) bool {
+	n, err := strconv.Atoi(pid)
+	if err != nil || n <= 0 {
+		return false
+	}
+	err = syscall.Kill(n, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// claimMounts registers hosts' mount paths as ours, replacing whatever we
+// claimed before. It fails without claiming anything if another live daemon
+// already manages one of them.
+func claimMounts(hosts []sshfsmon.Host) error {
+	self := strconv.Itoa(os.Getpid())
+	return updateRegistry(func(registry map[string][]string) error {
+		owners := make(map[string]string)
+		for pid, paths := range registry {
+			if pid == self {
+				continue
+			}
+			for _, path := range paths {
+				owners[path] = pid
+			}
+		}
+
+		var paths, conflicts []string
+		for _, host := range hosts {
+			if pid, taken := owners[host.MountPath]; taken {
+				conflicts = append(conflicts, fmt.Sprintf("%s (PID %s)", host.MountPath, pid))
+			}
+			paths = append(paths, host.MountPath)
+		}
+		if len(conflicts) > 0 {
+			sort.Strings(conflicts)
+			return fmt.Errorf("mount paths already managed by another SSHFS monitor: %s", strings.Join(conflicts, ", "))
+		}
+		registry[self] = paths
+		return nil
+	})
+}
+
+// releaseMounts removes our entry from the registry.
+func releaseMounts() {
+	self := strconv.Itoa(os.Getpid())
+	updateRegistry(func(registry map[string][]string) error {
+		delete(registry, self)
+		return nil
+	})
+}
+
+// registeredMountPaths returns the mount paths every running daemon has
+// claimed. A missing registry means there are none.
+func registeredMountPaths() ([]string, error) {
+	f, err := os.Open(registryPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mount registry: %v", err)
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH); err != nil {
+		return nil, fmt.Errorf("failed to lock mount registry: %v", err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	registry := make(map[string][]string)
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mount registry: %v", err)
+	}
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := json.Unmarshal(data, &registry); err != nil {
+			return nil, fmt.Errorf("failed to parse mount registry %s: %v", registryPath, err)
+		}
+	}
+	var paths []string
+	for pid, claimed := range registry {
+		if processAlive(pid) {
+			paths = append(paths, claimed...)
+		}
+	}
+	return paths, nil
+}
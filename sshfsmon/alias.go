@@ -0,0 +1,52 @@
+package sshfsmon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ALIAS_RESOLVE_TIMEOUT bounds ssh -G, which only reads the config.
+const ALIAS_RESOLVE_TIMEOUT = 5 * time.Second
+
+// probeTarget returns the host as the reachability checks should see it.
+// For an ssh config alias that is the HostName and Port the config gives
+// it, found with ssh -G. An alias reached through a ProxyJump or
+// ProxyCommand cannot be probed directly, so viaSSH asks for an ssh login
+// instead.
+func (m *Monitor) probeTarget(host Host) (target Host, viaSSH bool, err error) {
+	if !host.SSHAlias {
+		return host, false, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ALIAS_RESOLVE_TIMEOUT)
+	defer cancel()
+	args := append(m.sshConfigArgs(), "-G", host.IP)
+	output, err := exec.CommandContext(ctx, m.Config.SSHPath, args...).Output()
+	if err != nil {
+		return host, false, fmt.Errorf("cannot resolve ssh alias %s: %v", host.IP, err)
+	}
+
+	target = host
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		switch key {
+		case "hostname":
+			target.IP = value
+		case "port":
+			if port, err := strconv.Atoi(value); err == nil {
+				target.Port = port
+			}
+		case "proxyjump", "proxycommand":
+			if value != "" && value != "none" {
+				viaSSH = true
+			}
+		}
+	}
+	return target, viaSSH, nil
+}
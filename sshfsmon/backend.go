@@ -0,0 +1,121 @@
+package sshfsmon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Mount backends, chosen per host with backend=
+const (
+	BACKEND_SSHFS  = "sshfs"
+	BACKEND_RCLONE = "rclone"
+)
+
+// Mounter is a way of putting a host's mount in place. sshfs is the
+// default; backend=rclone mounts an rclone remote instead.
+type Mounter interface {
+	// Mount runs the mount command for the host until ctx is done. It
+	// returns the command line it ran and what the command printed on
+	// stderr, for the log.
+	Mount(ctx context.Context, host Host) (cmdline, stderr string, err error)
+	// Unmount tears down the mount at mountPoint.
+	Unmount(mountPoint string)
+	// IsHealthy reports whether the mount at mountPoint answers.
+	IsHealthy(mountPoint string) bool
+}
+
+// Mounter returns the backend that mounts host.
+func (m *Monitor) Mounter(host Host) Mounter {
+	if host.Backend == BACKEND_RCLONE {
+		return rcloneMounter{fuseMounter{m}}
+	}
+	return sshfsMounter{fuseMounter{m}}
+}
+
+// fuseMounter holds what the sshfs and rclone backends share: both are
+// FUSE mounts, torn down by the platform's unmount commands and judged by
+// whether a stat of the mount point answers.
+type fuseMounter struct {
+	m *Monitor
+}
+
+func (f fuseMounter) Unmount(mountPoint string) {
+	f.m.unmount(mountPoint)
+}
+
+func (f fuseMounter) IsHealthy(mountPoint string) bool {
+	return statWithin(mountPoint, STAT_TIMEOUT) == nil
+}
+
+type sshfsMounter struct{ fuseMounter }
+
+func (s sshfsMounter) Mount(ctx context.Context, host Host) (string, string, error) {
+	m := s.m
+	source := fmt.Sprintf("%s@%s:%s/", host.Username, host.IP, host.RemoteDir)
+	if host.SSHAlias {
+		source = fmt.Sprintf("%s:%s/", host.IP, host.RemoteDir)
+	}
+	args := append([]string{source, host.MountPath}, m.sshConfigArgs()...)
+	// Global extra arguments go last so they can override the options
+	args = append(append(args, "-o", m.MountOptions(host)), m.Config.SSHFSExtraArgs...)
+	name, args := m.asMountUser(m.Config.SSHFSPath, args...)
+	return m.runMountCommand(ctx, name, args)
+}
+
+type rcloneMounter struct{ fuseMounter }
+
+// Mount runs rclone mount with --daemon, so it returns once the mount is
+// up, as sshfs does.
+func (r rcloneMounter) Mount(ctx context.Context, host Host) (string, string, error) {
+	m := r.m
+	args := []string{"mount", host.Remote, host.MountPath, "--daemon"}
+	if host.ReadOnly {
+		args = append(args, "--read-only")
+	}
+	if m.Config.AllowOther {
+		args = append(args, "--allow-other")
+	}
+	// Local ownership of the files: the host's own, else the global one
+	for _, id := range []struct{ flag, host, global string }{
+		{"--uid", host.UID, m.Config.UID},
+		{"--gid", host.GID, m.Config.GID},
+	} {
+		if id.host != "" {
+			args = append(args, id.flag, id.host)
+		} else if id.global != "" {
+			args = append(args, id.flag, id.global)
+		}
+	}
+	name, args := m.asMountUser(m.Config.RclonePath, args...)
+	return m.runMountCommand(ctx, name, args)
+}
+
+// runMountCommand runs a mount command with the Monitor's runner and
+// returns its command line and stderr.
+func (m *Monitor) runMountCommand(ctx context.Context, name string, args []string) (string, string, error) {
+	_, stderr, err := m.runner()(ctx, name, args...)
+	return name + " " + strings.Join(args, " "), stderr, err
+}
+
+// checkBackend checks that a host's backend= and remote= go together, and
+// that it asks nothing of rclone that only sshfs can do.
+func checkBackend(host Host) error {
+	switch host.Backend {
+	case "", BACKEND_SSHFS:
+		if host.Remote != "" {
+			return fmt.Errorf("remote= needs backend=%s", BACKEND_RCLONE)
+		}
+	case BACKEND_RCLONE:
+		if host.Remote == "" {
+			return fmt.Errorf("backend=%s needs remote=", BACKEND_RCLONE)
+		}
+		if host.ProxyJump != "" {
+			return fmt.Errorf("jump= is not supported with backend=%s; configure it in the rclone remote", BACKEND_RCLONE)
+		}
+		if host.MkRemote {
+			return fmt.Errorf("mkremote is not supported with backend=%s", BACKEND_RCLONE)
+		}
+	}
+	return nil
+}
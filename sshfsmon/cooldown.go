@@ -0,0 +1,63 @@
+package sshfsmon
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// mountLog remembers when this Monitor last mounted each mount point, for
+// Config.RemountCooldown. Unlike Monitor.MountedAt it needs no daemon state,
+// so the cooldown holds in watch and dashboard too.
+type mountLog struct {
+	mu sync.Mutex
+	at map[string]time.Time
+}
+
+// mountsInit guards setting up the mount log and the in-flight mounts of a
+// Monitor not made by New.
+var mountsInit sync.Mutex
+
+func (m *Monitor) mountTimes() *mountLog {
+	mountsInit.Lock()
+	defer mountsInit.Unlock()
+	if m.mounts == nil {
+		m.mounts = &mountLog{}
+	}
+	return m.mounts
+}
+
+// noteMounted records a successful mount of path.
+func (m *Monitor) noteMounted(path string) {
+	if m.Config.RemountCooldown <= 0 {
+		return
+	}
+	log := m.mountTimes()
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	if log.at == nil {
+		log.at = make(map[string]time.Time)
+	}
+	log.at[path] = time.Now()
+}
+
+// cooldownHold returns why path, mounted by this Monitor less than
+// Config.RemountCooldown ago, must not be cleared yet, or "" if it may be.
+func (m *Monitor) cooldownHold(path string) string {
+	if m.Config.RemountCooldown <= 0 {
+		return ""
+	}
+	log := m.mountTimes()
+	log.mu.Lock()
+	mountedAt, ok := log.at[path]
+	log.mu.Unlock()
+	if !ok {
+		return ""
+	}
+	age := time.Since(mountedAt)
+	if age >= m.Config.RemountCooldown {
+		return ""
+	}
+	return fmt.Sprintf("not clearing it, mounted %s ago, within its %s remount cooldown",
+		age.Round(time.Second), m.Config.RemountCooldown)
+}
@@ -0,0 +1,108 @@
+package sshfsmon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// DEEP_CHECK_TIMEOUT bounds the round trip of a --deep-check through a mount.
+const DEEP_CHECK_TIMEOUT = 5 * time.Second
+
+// CheckDeep proves with Config.DeepCheck that data really flows through a
+// mounted host: a small sentinel file is written into the mount, read back
+// and removed, so the remote sshfs server has to answer rather than the
+// kernel. Read-only mounts, ro=true or refusing the write, get a listing of
+// the mount and a stat of its first entry instead. A host failing the check
+// is marked stale and no longer mounted.
+func (m *Monitor) CheckDeep(result *HostResult) {
+	if !m.Config.DeepCheck || !result.Mounted {
+		return
+	}
+	host := result.Host
+
+	done := make(chan error, 1)
+	go func() {
+		if host.ReadOnly {
+			done <- statFirstEntry(host.MountPath)
+			return
+		}
+		err := m.writeSentinel(host)
+		if errors.Is(err, syscall.EROFS) {
+			err = statFirstEntry(host.MountPath)
+		}
+		done <- err
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(DEEP_CHECK_TIMEOUT):
+		err = fmt.Errorf("timed out after %v", DEEP_CHECK_TIMEOUT)
+	}
+	if err == nil {
+		return
+	}
+	result.Mounted = false
+	result.Stale = true
+	result.Error = fmt.Errorf("mount at %s is stale; deep check failed: %v", host.MountPath, err)
+	m.logf(host, "Deep check of %s failed: %v", host.MountPath, err)
+}
+
+// writeSentinel writes a sentinel file into the host's mount, reads it back
+// and removes it again.
+func (m *Monitor) writeSentinel(host Host) error {
+	f, err := os.CreateTemp(host.MountPath, ".sshfs-connector-deep-check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	defer func() {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			m.logf(host, "WARN: Could not remove deep check file %s: %v", name, err)
+		}
+	}()
+
+	want := []byte(strconv.FormatInt(time.Now().UnixNano(), 10) + "\n")
+	_, err = f.Write(want)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("read back %q from %s, wrote %q", got, name, want)
+	}
+	return nil
+}
+
+// statFirstEntry is the read-only deep check: it lists the mount point and
+// stats the first entry, both of which go to the remote server with the
+// default cache=no. An empty mount only gets the listing.
+func statFirstEntry(mountPath string) error {
+	f, err := os.Open(mountPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(1)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Lstat(filepath.Join(mountPath, names[0]))
+	return err
+}
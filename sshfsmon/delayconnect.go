@@ -0,0 +1,42 @@
+package sshfsmon
+
+import "fmt"
+
+// AwaitingConnect reports whether the result is of a host that cannot be
+// reached and whose mount point holds a Config.DelayConnect mount: sshfs
+// has not connected, so the mount does not answer, but it is not stale
+// either. Checking it would only make sshfs try to connect and wait.
+func (m *Monitor) AwaitingConnect(result HostResult) bool {
+	return m.Config.DelayConnect && !(result.Reachable && result.SSHReachable) &&
+		IsMountPoint(result.Host.MountPath)
+}
+
+// mountDelayed is mountHost for a host that cannot be reached yet, with
+// Config.DelayConnect. A mount already in place is left alone to connect
+// once the host is back, anything else is mounted without connecting. The
+// host only counts as mounted once it can be reached and its mount answers.
+func (m *Monitor) mountDelayed(result HostResult) HostResult {
+	host := result.Host
+	waiting := fmt.Errorf("mounted with delay_connect, waiting for %s to be reachable", host.IP)
+	if m.AwaitingConnect(result) {
+		result.Error = waiting
+		m.logf(host, "Mount %s waiting for %s to be reachable (delay_connect)", host.MountPath, host.IP)
+		return result
+	}
+
+	if err := m.prepareMountDir(host); err != nil {
+		result.Error = err
+		return result
+	}
+	if m.checkShadow(&result) {
+		return result
+	}
+	result = m.runMount(result)
+	if !result.Mounted {
+		return result
+	}
+	m.RunHook(m.Config.PostMountHook, "post-mount", host)
+	result.Mounted = false
+	result.Error = waiting
+	return result
+}
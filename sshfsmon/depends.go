@@ -0,0 +1,151 @@
+package sshfsmon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnmetDependency is the error of a host skipped because the host its
+// after= names did not mount.
+var ErrUnmetDependency = errors.New("skipped due to unmet dependency")
+
+// CheckDependencies makes sure every after= names the name= of another
+// host and that no hosts wait for each other in a circle.
+func CheckDependencies(hosts []Host) error {
+	labeled := make(map[string][]Host)
+	for _, host := range hosts {
+		if host.Label != "" {
+			labeled[host.Label] = append(labeled[host.Label], host)
+		}
+	}
+	for _, host := range hosts {
+		if host.After != "" && len(labeled[host.After]) == 0 {
+			return fmt.Errorf("%s: after=%s names no host", host.Destination(), host.After)
+		}
+	}
+
+	// Depth-first over the labels; a label met again while its own
+	// prerequisites are being followed closes a cycle
+	const (
+		visiting = 1
+		done     = 2
+	)
+	seen := make(map[string]int)
+	var path []string
+	var visit func(label string) error
+	visit = func(label string) error {
+		switch seen[label] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), label)
+		case done:
+			return nil
+		}
+		seen[label] = visiting
+		path = append(path, label)
+		for _, host := range labeled[label] {
+			if host.After != "" {
+				if err := visit(host.After); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		seen[label] = done
+		return nil
+	}
+	for _, host := range hosts {
+		if host.Label != "" {
+			if err := visit(host.Label); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dependencyLayers splits the host indexes into layers that can each be
+// mounted in parallel: a host comes in the layer after the last one holding
+// a host its after= names. A prerequisite not among the hosts, left out by
+// --tag for one, holds nothing back. Cycles, which CheckDependencies
+// rejects on load, are broken arbitrarily.
+func dependencyLayers(hosts []Host) [][]int {
+	labeled := make(map[string][]int)
+	for i, host := range hosts {
+		if host.Label != "" {
+			labeled[host.Label] = append(labeled[host.Label], i)
+		}
+	}
+
+	depth := make([]int, len(hosts))
+	const unknown, visiting = -1, -2
+	for i := range depth {
+		depth[i] = unknown
+	}
+	var level func(i int) int
+	level = func(i int) int {
+		switch depth[i] {
+		case visiting:
+			return 0
+		case unknown:
+		default:
+			return depth[i]
+		}
+		depth[i] = visiting
+		d := 0
+		for _, j := range labeled[hosts[i].After] {
+			if l := level(j) + 1; l > d {
+				d = l
+			}
+		}
+		depth[i] = d
+		return d
+	}
+
+	var layers [][]int
+	for i := range hosts {
+		d := level(i)
+		for len(layers) <= d {
+			layers = append(layers, nil)
+		}
+		layers[d] = append(layers[d], i)
+	}
+	return layers
+}
+
+// processInLayers is ProcessHostsParallel for hosts with after=: each layer
+// of dependencyLayers is mounted in parallel once the previous one is done,
+// and a host whose prerequisite did not mount is skipped.
+func (m *Monitor) processInLayers(hosts []Host, layers [][]int) []HostResult {
+	results := make([]HostResult, len(hosts))
+	// A label is mounted when every host carrying it is
+	mounted := make(map[string]bool)
+	for _, layer := range layers {
+		var ready []int
+		var batch []Host
+		for _, i := range layer {
+			host := hosts[i]
+			if ok, known := mounted[host.After]; host.After != "" && known && !ok {
+				results[i] = HostResult{
+					Host:    host,
+					Skipped: true,
+					Error:   fmt.Errorf("%w: %s did not mount", ErrUnmetDependency, host.After),
+				}
+				m.logf(host, "Skipping %s: %s did not mount", host.MountPath, host.After)
+				continue
+			}
+			ready = append(ready, i)
+			batch = append(batch, host)
+		}
+		for j, result := range m.processHosts(batch) {
+			results[ready[j]] = result
+		}
+		for _, i := range layer {
+			if label := hosts[i].Label; label != "" {
+				ok, known := mounted[label]
+				mounted[label] = (ok || !known) && results[i].Mounted
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,101 @@
+package sshfsmon
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SSHFSProcesses returns the PIDs of the sshfs processes serving mountPath,
+// found through /proc. It finds none where /proc is not available.
+func (m *Monitor) SSHFSProcesses(mountPath string) []int {
+	var pids []int
+	dirs, _ := filepath.Glob("/proc/[0-9]*")
+	for _, dir := range dirs {
+		cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil {
+			continue
+		}
+		args := strings.Split(string(cmdline), "\x00")
+		if filepath.Base(args[0]) != "sshfs" && args[0] != m.Config.SSHFSPath {
+			continue
+		}
+		for _, arg := range args[1:] {
+			if arg == mountPath {
+				if pid, err := strconv.Atoi(filepath.Base(dir)); err == nil {
+					pids = append(pids, pid)
+				}
+				break
+			}
+		}
+	}
+	return pids
+}
+
+// MountHung reports whether something is mounted at path but a stat of it
+// fails or takes longer than timeout: sshfs is wedged rather than gone.
+func MountHung(path string, timeout time.Duration) bool {
+	return IsMountPoint(path) && statWithin(path, timeout) != nil
+}
+
+// errStatTimeout is statWithin's error for a stat that did not return in time.
+var errStatTimeout = errors.New("stat timed out")
+
+// statWithin stats path, the mount point itself and nothing below it, which
+// is enough for FUSE to ask sshfs without listing a possibly huge remote
+// directory. A stat stuck on a hung mount is abandoned after timeout, not
+// waited for; its goroutine returns whenever the kernel lets go.
+func statWithin(path string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := os.Stat(path)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errStatTimeout
+	}
+}
+
+// Escalate recovers a wedged mount that clearing the stale endpoint did not
+// fix: it kills the sshfs process serving the mount, runs the whole unmount
+// ladder and mounts the host again. Each step is logged.
+func (m *Monitor) Escalate(host Host) HostResult {
+	pids := m.SSHFSProcesses(host.MountPath)
+	if len(pids) == 0 {
+		m.logf(host, "Escalation for %s: no sshfs process found", host.MountPath)
+	}
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+			m.logf(host, "Escalation for %s: could not kill sshfs (PID %d): %v", host.MountPath, pid, err)
+		} else {
+			m.logf(host, "Escalation for %s: killed sshfs (PID %d)", host.MountPath, pid)
+		}
+	}
+
+	m.unmount(host.MountPath)
+	if IsMountPoint(host.MountPath) {
+		m.logf(host, "Escalation for %s: still mounted after the unmount ladder", host.MountPath)
+	} else {
+		m.logf(host, "Escalation for %s: unmounted", host.MountPath)
+		m.RunHook(m.Config.PostUnmountHook, "post-unmount", host)
+	}
+
+	result := m.MountHost(host)
+	if result.Mounted {
+		m.logf(host, "Escalation for %s: remounted", host.MountPath)
+	} else {
+		m.logf(host, "Escalation for %s: remount failed: %v", host.MountPath, result.Error)
+	}
+	return result
+}
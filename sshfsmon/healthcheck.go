@@ -0,0 +1,67 @@
+package sshfsmon
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// HEALTHCHECK_TIMEOUT is how long a host's healthcheck may run before it
+// counts as failed.
+const HEALTHCHECK_TIMEOUT = 10 * time.Second
+
+// CheckHealth runs the healthcheck= command of a mounted host and sets
+// result.HealthOK from its exit status. Hosts without a healthcheck pass.
+func (m *Monitor) CheckHealth(result *HostResult) {
+	host := result.Host
+	if host.HealthCheck == "" {
+		result.HealthOK = true
+		return
+	}
+
+	ctx := context.Background()
+	if m.Config.HealthCheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Config.HealthCheckTimeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", expandHealthCheck(host))
+	cmd.Env = append(os.Environ(), hostEnv(host)...)
+	// A check stuck on a hung mount takes whatever it started down with it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	output, err := cmd.CombinedOutput()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		m.logf(host, "Healthcheck of %s timed out after %v", host.MountPath, m.Config.HealthCheckTimeout)
+	case err != nil:
+		detail := stderrTail(string(output), STDERR_LOG_LINES)
+		if detail == "" {
+			detail = err.Error()
+		}
+		m.logf(host, "Healthcheck of %s failed: %s", host.MountPath, detail)
+	default:
+		result.HealthOK = true
+	}
+}
+
+// expandHealthCheck fills the placeholders of the host's healthcheck
+// command, {mount}, {host}, {port}, {user}, {remote_dir} and {label}, with
+// shell-quoted values.
+func expandHealthCheck(host Host) string {
+	return strings.NewReplacer(
+		"{mount}", shellQuote(host.MountPath),
+		"{host}", shellQuote(host.IP),
+		"{port}", strconv.Itoa(host.Port),
+		"{user}", shellQuote(host.Username),
+		"{remote_dir}", shellQuote(host.RemoteDir),
+		"{label}", shellQuote(host.Label),
+	).Replace(host.HealthCheck)
+}
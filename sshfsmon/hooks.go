@@ -0,0 +1,113 @@
+package sshfsmon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// HOOK_TIMEOUT is how long a post-mount or post-unmount hook may run
+// before it is killed.
+const HOOK_TIMEOUT = 60 * time.Second
+
+// hooksInit guards setting up the hook WaitGroup of a Monitor not made by
+// New, which may happen from several goroutines mounting at once.
+var hooksInit sync.Mutex
+
+// RunHook starts the hook command for the host in the background, unless
+// command is empty. The host is described in SSHFS_* environment variables,
+// and event ("post-mount" or "post-unmount") in SSHFS_EVENT. The exit
+// status is logged but never affects the mount. WaitHooks waits for the
+// hooks started so far.
+func (m *Monitor) RunHook(command, event string, host Host) {
+	m.startHook(command, event, " for "+host.MountPath, hostEnv(host), false, func(format string, args ...interface{}) {
+		m.logf(host, format, args...)
+	})
+}
+
+// RunFleetHook starts a hook about all hosts together rather than one, such
+// as the daemon's all-up and all-down hooks, with SSHFS_EVENT set to event
+// and env added to the environment. Its output is logged as well as its exit
+// status, through Notice.
+func (m *Monitor) RunFleetHook(command, event string, env []string) {
+	m.startHook(command, event, "", env, true, m.noticef)
+}
+
+// startHook runs a hook command in the background with the HookTimeout,
+// logging its outcome; subject says what it ran for.
+func (m *Monitor) startHook(command, event, subject string, env []string, logOutput bool, logf func(string, ...interface{})) {
+	if command == "" {
+		return
+	}
+	hooksInit.Lock()
+	if m.hooks == nil {
+		m.hooks = &sync.WaitGroup{}
+	}
+	hooksInit.Unlock()
+	m.hooks.Add(1)
+	go func() {
+		defer m.hooks.Done()
+
+		ctx := context.Background()
+		if m.Config.HookTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, m.Config.HookTimeout)
+			defer cancel()
+		}
+		cmd := exec.CommandContext(ctx, command)
+		cmd.Env = append(append(os.Environ(), "SSHFS_EVENT="+event), env...)
+		// Kill whatever the hook started along with it on timeout
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		cmd.Cancel = func() error {
+			return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+
+		start := time.Now()
+		output, err := cmd.CombinedOutput()
+		elapsed := time.Since(start)
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			logf("Hook %s%s killed after %v", event, subject, m.Config.HookTimeout)
+		case err != nil:
+			detail := stderrTail(string(output), STDERR_LOG_LINES)
+			if detail == "" {
+				detail = err.Error()
+			}
+			logf("Hook %s%s failed (%.3fs): %s", event, subject, elapsed.Seconds(), detail)
+		default:
+			message := fmt.Sprintf("Hook %s%s succeeded (%.3fs)", event, subject, elapsed.Seconds())
+			if detail := stderrTail(string(output), STDERR_LOG_LINES); logOutput && detail != "" {
+				message += ": " + detail
+			}
+			logf("%s", message)
+		}
+	}()
+}
+
+// hostEnv describes the host to hooks and healthchecks.
+func hostEnv(host Host) []string {
+	return []string{
+		"SSHFS_HOST=" + host.IP,
+		"SSHFS_PORT=" + strconv.Itoa(host.Port),
+		"SSHFS_USER=" + host.Username,
+		"SSHFS_REMOTE_DIR=" + host.RemoteDir,
+		"SSHFS_MOUNT_PATH=" + host.MountPath,
+		"SSHFS_LABEL=" + host.Label,
+	}
+}
+
+// WaitHooks waits for the hooks started by RunHook to finish, so a short
+// lived command does not exit from under them.
+func (m *Monitor) WaitHooks() {
+	hooksInit.Lock()
+	hooks := m.hooks
+	hooksInit.Unlock()
+	if hooks != nil {
+		hooks.Wait()
+	}
+}
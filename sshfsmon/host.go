@@ -0,0 +1,694 @@
+package sshfsmon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Host struct {
+	IP        string `json:"ip"`
+	MountPath string `json:"mount_path"`
+	Port      int    `json:"port"`
+	RemoteDir string `json:"remote_dir"`
+	Username  string `json:"username"`
+
+	// Per-host options, given as key=value tokens after the mount path
+	KnownHostsFile string `json:"known_hosts_file,omitempty"`
+	Options        string `json:"options,omitempty"` // extra sshfs -o options, overriding the defaults
+	Compress       bool   `json:"compress,omitempty"`
+	Cipher         string `json:"cipher,omitempty"`
+	MkRemote       bool   `json:"mkremote,omitempty"`   // create RemoteDir over ssh before mounting
+	ReadOnly       bool   `json:"readonly,omitempty"`   // mount ro and verify the remote refuses writes
+	Label          string `json:"label,omitempty"`      // display name from name=
+	ProxyJump      string `json:"proxy_jump,omitempty"` // [user@]bastion[:port] from jump=
+	UID            string `json:"uid,omitempty"`        // local owner of the mounted files, numeric
+	GID            string `json:"gid,omitempty"`
+	HealthCheck    string `json:"healthcheck,omitempty"` // local command judging a mount healthy
+	Shell          string `json:"shell,omitempty"`       // remote shell profile for the remote info probes
+	UIDFile        string `json:"uidfile,omitempty"`     // sshfs idmap=file uid map
+	GIDFile        string `json:"gidfile,omitempty"`
+	After          string `json:"after,omitempty"`     // name= of a host to mount first
+	Backend        string `json:"backend,omitempty"`   // mount backend, sshfs (default) or rclone
+	Remote         string `json:"remote,omitempty"`    // rclone remote:path for backend=rclone
+	SSHAlias       bool   `json:"ssh_alias,omitempty"` // IP is a Host alias of the ssh config
+
+	// Tags from tags=, for selecting groups of hosts
+	Tags []string `json:"tags,omitempty"`
+}
+
+// HasTag reports whether the host carries tag.
+func (h Host) HasTag(tag string) bool {
+	for _, t := range h.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Destination is the user@ip:port the host is reached at. Hosts mounting
+// different directories of one server share it, and with ControlMaster
+// their ssh connection.
+func (h Host) Destination() string {
+	return fmt.Sprintf("%s@%s:%d", h.Username, h.IP, h.Port)
+}
+
+type HostResult struct {
+	Host        Host          `json:"host"`
+	Reachable   bool          `json:"reachable"` // answers ping
+	PingTime    time.Duration `json:"ping_time_ns"`
+	CheckTime   time.Duration `json:"check_time_ns"`
+	Mounted     bool          `json:"mounted"`
+	MountTime   time.Duration `json:"mount_time_ns"`
+	ExecutedCmd string        `json:"executed_cmd"`
+	Error       error         `json:"-"`
+	RemoteInfo  RemoteInfo    `json:"remote_info"`
+
+	// RemoteInfoTime is how long the remote info probes took in total.
+	RemoteInfoTime time.Duration `json:"remote_info_time_ns"`
+
+	// Stale marks a dead mount that conservative mode left in place.
+	Stale bool `json:"stale,omitempty"`
+
+	// HealthOK reports whether a mounted host passed its healthcheck; hosts
+	// without one always do. A mount that fails it is DEGRADED.
+	HealthOK bool `json:"health_ok"`
+
+	// Recovery tells how a stale mount was dealt with, one of the
+	// RECOVERY_* values; empty when the mount was not stale or was left
+	// in place.
+	Recovery string `json:"recovery,omitempty"`
+
+	// SSHReachable reports whether the SSH port accepted a TCP connection.
+	// It is only probed for hosts that answer ping.
+	SSHReachable bool `json:"ssh_reachable"`
+
+	// ConsecutiveFailures counts the cycles in a row that ended without
+	// the host mounted. Only the long-running modes track it.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+
+	// ReadOnlyViolation marks a ro=true mount that accepted a test write
+	// anyway: the read-only export is not enforced.
+	ReadOnlyViolation bool `json:"readonly_violation,omitempty"`
+
+	// Shadowed marks a host that was not mounted because its mount
+	// directory has files in it, which the mount would hide.
+	Shadowed bool `json:"shadowed,omitempty"`
+
+	// Skipped marks a host that was not tried at all because a host its
+	// after= names did not mount; Error wraps ErrUnmetDependency.
+	Skipped bool `json:"skipped,omitempty"`
+
+	// IORead and IOWritten are the bytes the sshfs process serving the mount
+	// read and wrote, added up over the cycles and across remounts, where
+	// --io-stats samples them.
+	IORead    uint64 `json:"io_read_bytes,omitempty"`
+	IOWritten uint64 `json:"io_written_bytes,omitempty"`
+
+	// Breaker is the state of the host's circuit breaker, "closed", "open"
+	// or "half-open", in daemons that run one.
+	Breaker string `json:"breaker,omitempty"`
+}
+
+// RemoteInfo holds the remote info fields probed on a mounted host. Fields
+// that were not selected in Config.RemoteFields stay empty.
+type RemoteInfo struct {
+	Hostname string `json:"hostname"`
+	Uptime   string `json:"uptime"`
+	MAC      string `json:"mac"`
+	LoadAvg  string `json:"loadavg,omitempty"`
+	DiskFree string `json:"diskfree,omitempty"`
+}
+
+// Field returns the value of the named field, as listed in REMOTE_FIELDS.
+func (r RemoteInfo) Field(name string) string {
+	if p := r.field(name); p != nil {
+		return *p
+	}
+	return ""
+}
+
+func (r *RemoteInfo) field(name string) *string {
+	switch name {
+	case "hostname":
+		return &r.Hostname
+	case "uptime":
+		return &r.Uptime
+	case "mac":
+		return &r.MAC
+	case "loadavg":
+		return &r.LoadAvg
+	case "diskfree":
+		return &r.DiskFree
+	}
+	return nil
+}
+
+// MarshalJSON renders Error as its message, since error values have no
+// useful JSON form of their own.
+func (r HostResult) MarshalJSON() ([]byte, error) {
+	type plain HostResult
+	var errMsg string
+	if r.Error != nil {
+		errMsg = r.Error.Error()
+	}
+	return json.Marshal(struct {
+		plain
+		Error string `json:"error,omitempty"`
+	}{plain(r), errMsg})
+}
+
+// LoadHosts reads the hosts list from source: a file path, "-" for stdin,
+// or an http(s) URL.
+func (m *Monitor) LoadHosts(source string) ([]Host, error) {
+	r, err := m.OpenHostsSource(source)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	hosts, err := m.parseHosts(r, m.HostsFormatFor(source))
+	if err != nil {
+		return nil, fmt.Errorf("error reading hosts from %s: %v", SourceName(source), err)
+	}
+	if len(hosts) == 0 {
+		if source == "-" {
+			return nil, fmt.Errorf("no hosts on stdin")
+		}
+		return nil, fmt.Errorf("no hosts found in %s", source)
+	}
+	if err := CheckDependencies(hosts); err != nil {
+		return nil, fmt.Errorf("error reading hosts from %s: %v", SourceName(source), err)
+	}
+	return hosts, nil
+}
+
+// SourceName names a hosts source in messages: "stdin" for "-", else the
+// source as given.
+func SourceName(source string) string {
+	if source == "-" {
+		return "stdin"
+	}
+	return source
+}
+
+// OpenHostsSource opens a hosts source: a file, "-" for stdin, or an
+// http(s) URL.
+func (m *Monitor) OpenHostsSource(source string) (io.ReadCloser, error) {
+	if source == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := &http.Client{Timeout: time.Duration(m.Config.Timeout) * time.Second}
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching hosts from %s: %v", source, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error fetching hosts from %s: %s", source, resp.Status)
+		}
+		return resp.Body, nil
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("error opening hosts file %s: %v", source, err)
+	}
+	return file, nil
+}
+
+// ParseHosts parses the hosts file format, one host per line:
+//
+//	[user@]host mount_path [port] [remote_dir] [key=value ...]
+//
+// Relative mount paths are resolved against the configured mount base, or
+// the line's base= option. A mount path of "-" is named after the host.
+// With HostsFormat "csv" or "toml" the input is CSV or TOML instead, see
+// ParseHostRecord and ParseHostTable.
+func (m *Monitor) ParseHosts(r io.Reader) ([]Host, error) {
+	return m.parseHosts(r, m.Config.HostsFormat)
+}
+
+// HostsFormatFor resolves the "auto" hosts format for a source by its
+// extension: .csv and .toml files are read as such, anything else, stdin
+// included, as fields.
+func (m *Monitor) HostsFormatFor(source string) string {
+	if m.Config.HostsFormat != "auto" {
+		return m.Config.HostsFormat
+	}
+	if end := strings.IndexAny(source, "?#"); end >= 0 && strings.Contains(source, "://") {
+		source = source[:end]
+	}
+	switch strings.ToLower(filepath.Ext(source)) {
+	case ".csv":
+		return "csv"
+	case ".toml":
+		return "toml"
+	}
+	return "fields"
+}
+
+func (m *Monitor) parseHosts(r io.Reader, format string) ([]Host, error) {
+	switch format {
+	case "csv":
+		return m.parseCSVHosts(r)
+	case "toml":
+		return m.parseTOMLHosts(r)
+	}
+
+	var hosts []Host
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		lineHosts, err := m.ParseHostLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		hosts = append(hosts, lineHosts...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return hosts, nil
+}
+
+// ParseHostLine parses a single hosts file line into its hosts: one, or one
+// per target of a {remote_dir:mount_path,...} list, see expandTargets. It
+// returns none for blank lines, comments and lines without a mount path. A
+// port that is not a number from 1 to 65535 falls back to 22, as it always
+// has; CheckHostLine reports it instead.
+func (m *Monitor) ParseHostLine(line string) ([]Host, error) {
+	return m.parseHostLine(line, false)
+}
+
+// CheckHostLine is ParseHostLine for the validate command: it also rejects
+// an invalid port.
+func (m *Monitor) CheckHostLine(line string) ([]Host, error) {
+	return m.parseHostLine(line, true)
+}
+
+func (m *Monitor) parseHostLine(line string, strict bool) ([]Host, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	parts, err := splitFields(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) < 2 {
+		return nil, nil
+	}
+
+	// Extract username and host
+	var username, hostIP string
+	if strings.Contains(parts[0], "@") {
+		splitHost := strings.SplitN(parts[0], "@", 2)
+		username = splitHost[0]
+		hostIP = splitHost[1]
+	} else {
+		username = "root"
+		hostIP = parts[0]
+	}
+
+	host := Host{
+		IP:        hostIP,
+		MountPath: parts[1],
+		Port:      22,
+		RemoteDir: "/root",
+		Username:  username,
+	}
+
+	// Split the remaining fields into positional ones and key=value options
+	base := m.Config.MountBase
+	var positional []string
+	var targets string
+	for _, part := range parts[2:] {
+		if strings.HasPrefix(part, "{") {
+			if targets != "" {
+				return nil, fmt.Errorf("only one {remote_dir:mount_path,...} list per line")
+			}
+			targets = part
+			continue
+		}
+		key, value, isOption := strings.Cut(part, "=")
+		if !isOption {
+			positional = append(positional, part)
+			continue
+		}
+		if key == "base" {
+			// base= only affects how this line's mount path resolves
+			if !filepath.IsAbs(value) {
+				return nil, fmt.Errorf("base must be an absolute path, got %q", value)
+			}
+			base = value
+			continue
+		}
+		if err := applyHostOption(&host, key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkBackend(host); err != nil {
+		return nil, err
+	}
+	if err := resolveMountPath(&host, base); err != nil {
+		return nil, err
+	}
+
+	// Handle port
+	if len(positional) > 0 {
+		port, err := parsePort(positional[0])
+		if err == nil {
+			host.Port = port
+		} else if strict {
+			return nil, err
+		}
+	}
+
+	// Handle remote directory
+	if len(positional) > 1 {
+		if targets != "" {
+			return nil, fmt.Errorf("remote_dir %q and a {remote_dir:mount_path,...} list exclude each other", positional[1])
+		}
+		host.RemoteDir = positional[1]
+	}
+
+	if targets != "" {
+		return expandTargets(host, targets)
+	}
+	return []Host{host}, nil
+}
+
+// splitFields splits a hosts file line on whitespace, except inside double
+// quotes, which are dropped: healthcheck="test -w {mount}/.probe" is one
+// field.
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	inField, quoted := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case !quoted && unicode.IsSpace(r):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// expandTargets turns a line's {remote_dir:mount_path,...} list into one
+// host per pair, otherwise alike, so a server exporting several directories
+// takes a single line. The line's mount path is the targets' shared parent:
+// relative target mount paths are resolved against it.
+func expandTargets(host Host, list string) ([]Host, error) {
+	inner, closed := strings.CutSuffix(strings.TrimPrefix(list, "{"), "}")
+	if !closed || inner == "" {
+		return nil, fmt.Errorf("target list %q must be {remote_dir:mount_path,...} without spaces", list)
+	}
+	var hosts []Host
+	for _, pair := range strings.Split(inner, ",") {
+		remoteDir, mountPath, ok := strings.Cut(pair, ":")
+		if !ok || remoteDir == "" || mountPath == "" {
+			return nil, fmt.Errorf("target %q must be remote_dir:mount_path", pair)
+		}
+		target := host
+		target.RemoteDir = remoteDir
+		target.MountPath = mountPath
+		if err := resolveMountPath(&target, host.MountPath); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, target)
+	}
+	return hosts, nil
+}
+
+// resolveMountPath makes the host's mount path absolute. "-" names the mount
+// after the remote host; a relative path is joined to base and must stay
+// inside it.
+func resolveMountPath(host *Host, base string) error {
+	if host.MountPath == "-" {
+		host.MountPath = autoMountName(host.IP)
+	}
+	if !filepath.IsAbs(host.MountPath) {
+		if cleaned := filepath.Clean(host.MountPath); cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return fmt.Errorf("mount path %q escapes %s", host.MountPath, base)
+		}
+		host.MountPath = filepath.Join(base, host.MountPath)
+	}
+	return nil
+}
+
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", value)
+	}
+	return port, nil
+}
+
+// MAX_LABEL caps host labels so they fit the status displays.
+const MAX_LABEL = 16
+
+func validLabel(label string) bool {
+	if label == "" || len(label) > MAX_LABEL {
+		return false
+	}
+	for _, r := range label {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// autoMountName derives a mount directory name from the host's name: the
+// first label of a hostname, or of the reverse DNS name of an IP address.
+// An address without a reverse entry is used as is.
+func autoMountName(ip string) string {
+	name := ip
+	if net.ParseIP(ip) != nil {
+		if names, err := net.LookupAddr(ip); err == nil && len(names) > 0 {
+			name = strings.TrimSuffix(names[0], ".")
+		} else {
+			return ip
+		}
+	}
+	if label, _, _ := strings.Cut(name, "."); label != "" {
+		return label
+	}
+	return name
+}
+
+// HostOption documents a key=value token of the hosts file. HOST_OPTIONS
+// lists every key ParseHostLine accepts, for the sample hosts file; add a
+// key there when applyHostOption learns it.
+type HostOption struct {
+	Key     string
+	Example string
+	Doc     string
+}
+
+var HOST_OPTIONS = []HostOption{
+	{"name", "backup-nyc", "label shown in place of \"Host N\" (up to 16 of a-z 0-9 . _ -)"},
+	{"tags", "prod,backup", "tags for selecting hosts with --tag (names as for name=)"},
+	{"base", "/mnt/backup", "resolve a relative mount_path here instead of the mount base"},
+	{"known_hosts", "/etc/ssh/lab_known_hosts", "ssh UserKnownHostsFile for this host"},
+	{"opts", "reconnect,ServerAliveInterval=15", "extra sshfs -o options, overriding the defaults"},
+	{"compress", "yes", "enable ssh compression for this mount (yes or no)"},
+	{"cipher", "aes128-ctr", "ssh cipher list"},
+	{"mkremote", "true", "create remote_dir over ssh before mounting"},
+	{"ro", "true", "mount read-only and check each cycle that writes really fail"},
+	{"jump", "ops@bastion:22", "reach the host through this ssh jump host (one hop)"},
+	{"uid", "1000", "local owner of the mounted files (number or user name)"},
+	{"gid", "media", "local group of the mounted files (number or group name)"},
+	{"shell", "busybox", "remote shell for the remote info probes: posix (default), busybox, minimal or none"},
+	{"healthcheck", "/usr/local/bin/probe", "local command judging the mount; failing it marks the host DEGRADED"},
+	{"after", "gateway", "mount only once the host with this name= has mounted"},
+	{"uidfile", "/etc/sshfs/nas.uids", "map remote to local users with this file (sshfs idmap=file)"},
+	{"gidfile", "/etc/sshfs/nas.gids", "map remote to local groups with this file (sshfs idmap=file)"},
+	{"backend", "rclone", "mount with sshfs (default) or rclone"},
+	{"remote", "nas-sftp:/srv/data", "rclone remote:path to mount, with backend=rclone"},
+	{"alias", "true", "the address is a Host alias of the ssh config, which supplies its user and port"},
+}
+
+// applyHostOption sets a per-host key=value option from the hosts file.
+func applyHostOption(host *Host, key, value string) error {
+	if value == "" {
+		return fmt.Errorf("option %s needs a value", key)
+	}
+	switch key {
+	case "known_hosts":
+		host.KnownHostsFile = value
+	case "opts":
+		host.Options = value
+	case "compress":
+		switch value {
+		case "yes":
+			host.Compress = true
+		case "no":
+			host.Compress = false
+		default:
+			return fmt.Errorf("compress must be yes or no, got %q", value)
+		}
+	case "mkremote":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("mkremote must be true or false, got %q", value)
+		}
+		host.MkRemote = enabled
+	case "alias":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("alias must be true or false, got %q", value)
+		}
+		host.SSHAlias = enabled
+	case "ro":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("ro must be true or false, got %q", value)
+		}
+		host.ReadOnly = enabled
+	case "name":
+		if !validLabel(value) {
+			return fmt.Errorf("name must be 1-%d letters, digits, '.', '_' or '-', got %q", MAX_LABEL, value)
+		}
+		host.Label = value
+	case "cipher":
+		if strings.ContainsAny(value, " \t\"'") {
+			return fmt.Errorf("invalid cipher %q", value)
+		}
+		host.Cipher = value
+	case "uid":
+		id, err := LookupUID(value)
+		if err != nil {
+			return err
+		}
+		host.UID = id
+	case "gid":
+		id, err := LookupGID(value)
+		if err != nil {
+			return err
+		}
+		host.GID = id
+	case "healthcheck":
+		host.HealthCheck = value
+	case "after":
+		if !validLabel(value) {
+			return fmt.Errorf("after must name a host by its name=, got %q", value)
+		}
+		host.After = value
+	case "uidfile", "gidfile":
+		if err := checkIDMapFile(value); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		if key == "uidfile" {
+			host.UIDFile = value
+		} else {
+			host.GIDFile = value
+		}
+	case "backend":
+		if value != BACKEND_SSHFS && value != BACKEND_RCLONE {
+			return fmt.Errorf("backend must be %s or %s, got %q", BACKEND_SSHFS, BACKEND_RCLONE, value)
+		}
+		host.Backend = value
+	case "remote":
+		if !strings.Contains(value, ":") {
+			return fmt.Errorf("remote must be an rclone remote:path, got %q", value)
+		}
+		host.Remote = value
+	case "shell":
+		if !validShell(value) {
+			return fmt.Errorf("shell must be one of %s, got %q", shellNames(), value)
+		}
+		host.Shell = value
+	case "tags":
+		host.Tags = nil
+		for _, tag := range strings.Split(value, ",") {
+			if !validLabel(tag) {
+				return fmt.Errorf("tags must be comma-separated names of 1-%d letters, digits, '.', '_' or '-', got %q", MAX_LABEL, value)
+			}
+			host.Tags = append(host.Tags, tag)
+		}
+	case "jump":
+		if _, _, _, err := splitJump(value); err != nil {
+			return err
+		}
+		host.ProxyJump = value
+	default:
+		return fmt.Errorf("unknown option %q", key)
+	}
+	return nil
+}
+
+// checkIDMapFile makes sure a uidfile= or gidfile= can be read now rather
+// than have sshfs fail on it at mount time. Commas would end the -o option.
+func checkIDMapFile(path string) error {
+	if strings.Contains(path, ",") {
+		return fmt.Errorf("path must not contain a comma, got %q", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// splitJump splits a jump= value, [user@]host[:port], into its parts. Only a
+// single hop is supported, since sshfs options are comma separated.
+func splitJump(jump string) (user, host string, port int, err error) {
+	if jump == "" || strings.ContainsAny(jump, ", \t\"'") {
+		return "", "", 0, fmt.Errorf("jump must be a single [user@]host[:port], got %q", jump)
+	}
+	host = jump
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		user, host = host[:at], host[at+1:]
+	}
+	port = 22
+	if h, p, splitErr := net.SplitHostPort(host); splitErr == nil {
+		if port, err = parsePort(p); err != nil {
+			return "", "", 0, fmt.Errorf("jump: %v", err)
+		}
+		host = h
+	}
+	if host == "" {
+		return "", "", 0, fmt.Errorf("jump must be a single [user@]host[:port], got %q", jump)
+	}
+	return user, host, port, nil
+}
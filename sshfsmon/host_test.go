@@ -0,0 +1,52 @@
+package sshfsmon
+
+import "testing"
+
+func TestParseHostLinePort(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantPort  int
+		wantCheck bool // whether CheckHostLine accepts the line
+	}{
+		{"default", "root@10.0.0.1 /mnt/a", 22, true},
+		{"given", "root@10.0.0.1 /mnt/a 2222", 2222, true},
+		{"not a number", "root@10.0.0.1 /mnt/a ssh", 22, false},
+		{"out of range", "root@10.0.0.1 /mnt/a 70000", 22, false},
+		{"zero", "root@10.0.0.1 /mnt/a 0", 22, false},
+	}
+	m := New(DefaultConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := m.ParseHostLine(tt.line)
+			if err != nil || len(hosts) != 1 {
+				t.Fatalf("ParseHostLine = %v, %v; want one host", hosts, err)
+			}
+			if hosts[0].Port != tt.wantPort {
+				t.Errorf("port %d, want %d", hosts[0].Port, tt.wantPort)
+			}
+			if _, err := m.CheckHostLine(tt.line); (err == nil) != tt.wantCheck {
+				t.Errorf("CheckHostLine error %v, want accepted %v", err, tt.wantCheck)
+			}
+		})
+	}
+}
+
+func TestParseHostLineQuoted(t *testing.T) {
+	m := New(DefaultConfig())
+	hosts, err := m.ParseHostLine(`root@10.0.0.1 /mnt/a 2222 healthcheck="test -w {mount}/.probe" name=a`)
+	if err != nil || len(hosts) != 1 {
+		t.Fatalf("ParseHostLine = %v, %v; want one host", hosts, err)
+	}
+	host := hosts[0]
+	if host.HealthCheck != "test -w {mount}/.probe" {
+		t.Errorf("healthcheck %q, want %q", host.HealthCheck, "test -w {mount}/.probe")
+	}
+	if host.Port != 2222 || host.Label != "a" {
+		t.Errorf("port %d label %q, want 2222 and a", host.Port, host.Label)
+	}
+
+	if _, err := m.ParseHostLine(`root@10.0.0.1 /mnt/a healthcheck="test -w {mount}`); err == nil {
+		t.Error("an unterminated quote was accepted")
+	}
+}
@@ -0,0 +1,100 @@
+package sshfsmon
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// NewHostsCSVReader returns a CSV reader set up for hosts files: '#'
+// comments and rows with fewer than all five columns are allowed.
+func NewHostsCSVReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader
+}
+
+// parseCSVHosts reads hosts from CSV with a header row followed by
+//
+//	user@ip,mount_path,port,remote_dir,label
+//
+// rows. Only the first two columns are required; empty ones get the same
+// defaults as the fields format.
+func (m *Monitor) parseCSVHosts(r io.Reader) ([]Host, error) {
+	reader := NewHostsCSVReader(r)
+	var hosts []Host
+	header := true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header {
+			header = false
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		host, ok, err := m.ParseHostRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if ok {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
+
+// ParseHostRecord parses one CSV hosts row. ok is false for blank rows.
+func (m *Monitor) ParseHostRecord(record []string) (host Host, ok bool, err error) {
+	column := func(i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	if len(record) > 5 {
+		return Host{}, false, fmt.Errorf("expected at most 5 columns (user@ip,mount_path,port,remote_dir,label), got %d", len(record))
+	}
+	if column(0) == "" && column(1) == "" {
+		return Host{}, false, nil
+	}
+	if column(0) == "" || column(1) == "" {
+		return Host{}, false, fmt.Errorf("user@ip and mount_path are required")
+	}
+
+	host = Host{
+		IP:        column(0),
+		MountPath: column(1),
+		Port:      22,
+		RemoteDir: "/root",
+		Username:  "root",
+	}
+	if username, hostIP, found := strings.Cut(host.IP, "@"); found {
+		host.Username = username
+		host.IP = hostIP
+	}
+	if err := resolveMountPath(&host, m.Config.MountBase); err != nil {
+		return Host{}, false, err
+	}
+	if value := column(2); value != "" {
+		if host.Port, err = parsePort(value); err != nil {
+			return Host{}, false, err
+		}
+	}
+	if value := column(3); value != "" {
+		host.RemoteDir = value
+	}
+	if value := column(4); value != "" {
+		if err := applyHostOption(&host, "name", value); err != nil {
+			return Host{}, false, err
+		}
+	}
+	return host, true, nil
+}
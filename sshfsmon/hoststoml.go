@@ -0,0 +1,283 @@
+package sshfsmon
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// HostTable is one [[hosts]] table of a TOML hosts file, its values in the
+// order they were written. Integers and booleans are kept as written.
+type HostTable struct {
+	Line   int // line of the [[hosts]] header
+	Keys   []string
+	Values map[string]string
+}
+
+// HostsParseError is a syntax error in a TOML hosts file. The reader skips
+// the rest of the broken table, so reading can go on with the next one.
+type HostsParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *HostsParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// HostsTOMLReader reads the [[hosts]] tables of a TOML hosts file:
+//
+//	[[hosts]]
+//	host = "backup@10.0.0.5"
+//	mount_path = "/mnt/backup"
+//	port = 2222
+//	compress = true
+//
+// Only this subset of TOML is understood: [[hosts]] headers, bare keys,
+// quoted strings, integers and booleans, and comments.
+type HostsTOMLReader struct {
+	scanner *bufio.Scanner
+	line    int
+	next    int              // line of a [[hosts]] header read ahead, 0 if none
+	pending *HostsParseError // a bad header read ahead
+	err     error
+}
+
+func NewHostsTOMLReader(r io.Reader) *HostsTOMLReader {
+	return &HostsTOMLReader{scanner: bufio.NewScanner(r)}
+}
+
+// Read returns the next table, or io.EOF after the last one. A
+// *HostsParseError only affects the table it is returned for.
+func (t *HostsTOMLReader) Read() (HostTable, error) {
+	if t.err != nil {
+		return HostTable{}, t.err
+	}
+
+	var table *HostTable
+	var tableErr error
+	if t.next > 0 {
+		table = &HostTable{Line: t.next, Values: make(map[string]string)}
+		t.next = 0
+	}
+	if t.pending != nil {
+		tableErr, t.pending = t.pending, nil
+	}
+	for t.scanner.Scan() {
+		t.line++
+		line := strings.TrimSpace(t.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			header, err := tomlHeader(line)
+			if err == nil && header != "hosts" {
+				err = fmt.Errorf("only [[hosts]] tables are supported, got %s", line)
+			}
+			if err != nil {
+				// A bad header ends the table before it, and its own
+				// keys are skipped
+				if table != nil || tableErr != nil {
+					t.pending = &HostsParseError{t.line, err}
+					break
+				}
+				tableErr = &HostsParseError{t.line, err}
+				continue
+			}
+			if table != nil || tableErr != nil {
+				t.next = t.line
+				break
+			}
+			table = &HostTable{Line: t.line, Values: make(map[string]string)}
+			continue
+		}
+
+		if tableErr != nil {
+			continue
+		}
+		if table == nil {
+			tableErr = &HostsParseError{t.line, fmt.Errorf("key outside a [[hosts]] table")}
+			continue
+		}
+		key, value, err := tomlKeyValue(line)
+		if err == nil {
+			if _, dup := table.Values[key]; dup {
+				err = fmt.Errorf("duplicate key %q", key)
+			}
+		}
+		if err != nil {
+			tableErr = &HostsParseError{t.line, err}
+			continue
+		}
+		table.Keys = append(table.Keys, key)
+		table.Values[key] = value
+	}
+
+	if tableErr != nil {
+		return HostTable{}, tableErr
+	}
+	if err := t.scanner.Err(); err != nil {
+		t.err = err
+		return HostTable{}, err
+	}
+	if table == nil {
+		t.err = io.EOF
+		return HostTable{}, io.EOF
+	}
+	return *table, nil
+}
+
+// tomlHeader returns the name of a [[name]] array table header.
+func tomlHeader(line string) (string, error) {
+	if comment := strings.Index(line, "#"); comment >= 0 {
+		line = strings.TrimSpace(line[:comment])
+	}
+	if !strings.HasPrefix(line, "[[") || !strings.HasSuffix(line, "]]") {
+		return "", fmt.Errorf("only [[hosts]] tables are supported, got %s", line)
+	}
+	return strings.TrimSpace(line[2 : len(line)-2]), nil
+}
+
+// tomlKeyValue parses a key = value line.
+func tomlKeyValue(line string) (key, value string, err error) {
+	key, rest, found := strings.Cut(line, "=")
+	key = strings.TrimSpace(key)
+	if !found || key == "" {
+		return "", "", fmt.Errorf("expected key = value, got %q", line)
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return "", "", fmt.Errorf("invalid key %q", key)
+		}
+	}
+
+	rest = strings.TrimSpace(rest)
+	switch {
+	case strings.HasPrefix(rest, `"`):
+		end := 1
+		for end < len(rest) && rest[end] != '"' {
+			if rest[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(rest) {
+			return "", "", fmt.Errorf("unterminated string for %s", key)
+		}
+		if value, err = strconv.Unquote(rest[:end+1]); err != nil {
+			return "", "", fmt.Errorf("invalid string for %s: %v", key, err)
+		}
+		rest = rest[end+1:]
+	case strings.HasPrefix(rest, "'"):
+		end := strings.Index(rest[1:], "'")
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string for %s", key)
+		}
+		value, rest = rest[1:end+1], rest[end+2:]
+	default:
+		value, rest, _ = strings.Cut(rest, "#")
+		value = strings.TrimSpace(value)
+		rest = ""
+		if value != "true" && value != "false" {
+			if _, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 10, 64); err != nil {
+				return "", "", fmt.Errorf("unsupported value for %s: %s (use a quoted string, integer or boolean)", key, value)
+			}
+			value = strings.ReplaceAll(value, "_", "")
+		}
+	}
+
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", "", fmt.Errorf("unexpected %q after the value of %s", rest, key)
+	}
+	return key, value, nil
+}
+
+// parseTOMLHosts reads hosts from a TOML file of [[hosts]] tables.
+func (m *Monitor) parseTOMLHosts(r io.Reader) ([]Host, error) {
+	reader := NewHostsTOMLReader(r)
+	var hosts []Host
+	for {
+		table, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		host, err := m.ParseHostTable(table)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", table.Line, err)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// ParseHostTable turns a [[hosts]] table into a Host. host ([user@]ip) and
+// mount_path are required; user, port, remote_dir and base stand in for the
+// positional fields of the fields format, and every other key is a per-host
+// option with the same name and meaning as there.
+func (m *Monitor) ParseHostTable(table HostTable) (Host, error) {
+	target, mountPath := table.Values["host"], table.Values["mount_path"]
+	if target == "" || mountPath == "" {
+		return Host{}, fmt.Errorf("host and mount_path are required")
+	}
+
+	host := Host{
+		IP:        target,
+		MountPath: mountPath,
+		Port:      22,
+		RemoteDir: "/root",
+		Username:  "root",
+	}
+	if username, hostIP, found := strings.Cut(target, "@"); found {
+		host.Username = username
+		host.IP = hostIP
+	}
+
+	base := m.Config.MountBase
+	for _, key := range table.Keys {
+		value := table.Values[key]
+		var err error
+		switch key {
+		case "host", "mount_path":
+		case "user":
+			host.Username = value
+		case "port":
+			host.Port, err = parsePort(value)
+		case "remote_dir":
+			host.RemoteDir = value
+		case "base":
+			if !filepath.IsAbs(value) {
+				return Host{}, fmt.Errorf("base must be an absolute path, got %q", value)
+			}
+			base = value
+		case "compress":
+			// A TOML boolean, or yes/no as in the fields format
+			switch value {
+			case "true":
+				value = "yes"
+			case "false":
+				value = "no"
+			}
+			err = applyHostOption(&host, key, value)
+		default:
+			err = applyHostOption(&host, key, value)
+		}
+		if err != nil {
+			return Host{}, err
+		}
+	}
+
+	if err := checkBackend(host); err != nil {
+		return Host{}, err
+	}
+	if err := resolveMountPath(&host, base); err != nil {
+		return Host{}, err
+	}
+	return host, nil
+}
@@ -0,0 +1,31 @@
+package sshfsmon
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// LookupUID returns the numeric uid for a uid or user name.
+func LookupUID(value string) (string, error) {
+	if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return strconv.FormatUint(id, 10), nil
+	}
+	u, err := user.Lookup(value)
+	if err != nil {
+		return "", fmt.Errorf("uid must be a number or a local user name, got %q", value)
+	}
+	return u.Uid, nil
+}
+
+// LookupGID returns the numeric gid for a gid or group name.
+func LookupGID(value string) (string, error) {
+	if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return strconv.FormatUint(id, 10), nil
+	}
+	g, err := user.LookupGroup(value)
+	if err != nil {
+		return "", fmt.Errorf("gid must be a number or a local group name, got %q", value)
+	}
+	return g.Gid, nil
+}
@@ -0,0 +1,77 @@
+package sshfsmon
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrInFlight is the error of a host skipped because an earlier call, such
+// as a cycle ProcessHostsUntil gave up on, is still mounting it.
+var ErrInFlight = errors.New("still being mounted by an earlier cycle")
+
+// inFlight holds the mount paths being mounted right now, so that no two
+// goroutines ever mount one path at the same time.
+type inFlight struct {
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+func (m *Monitor) inFlight() *inFlight {
+	mountsInit.Lock()
+	defer mountsInit.Unlock()
+	if m.busy == nil {
+		m.busy = &inFlight{}
+	}
+	return m.busy
+}
+
+// claim marks path as being mounted, and reports false if it already was.
+func (m *Monitor) claim(path string) bool {
+	busy := m.inFlight()
+	busy.mu.Lock()
+	defer busy.mu.Unlock()
+	if busy.paths[path] {
+		return false
+	}
+	if busy.paths == nil {
+		busy.paths = make(map[string]bool)
+	}
+	busy.paths[path] = true
+	return true
+}
+
+func (m *Monitor) release(path string) {
+	busy := m.inFlight()
+	busy.mu.Lock()
+	defer busy.mu.Unlock()
+	delete(busy.paths, path)
+}
+
+// ProcessHostsUntil is ProcessHostsParallel that stops waiting when ctx is
+// done. It then returns no results and how many of the hosts were still
+// being mounted. Those mounts run on in the background, bounded by the
+// mount and stat timeouts, their results dropped; until they finish, later
+// calls skip the hosts with ErrInFlight.
+func (m *Monitor) ProcessHostsUntil(ctx context.Context, hosts []Host) ([]HostResult, int) {
+	done := make(chan []HostResult, 1)
+	go func() {
+		done <- m.ProcessHostsParallel(hosts)
+	}()
+	select {
+	case results := <-done:
+		return results, 0
+	case <-ctx.Done():
+	}
+
+	busy := m.inFlight()
+	busy.mu.Lock()
+	defer busy.mu.Unlock()
+	pending := 0
+	for _, host := range hosts {
+		if busy.paths[host.MountPath] {
+			pending++
+		}
+	}
+	return nil, pending
+}
@@ -0,0 +1,133 @@
+package sshfsmon
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// KnownHostsPath is the known_hosts file ssh uses for the host: its own
+// known_hosts= file, or the configured one. It is empty when neither is set.
+func (m *Monitor) KnownHostsPath(host Host) string {
+	if host.KnownHostsFile != "" {
+		return host.KnownHostsFile
+	}
+	return m.Config.KnownHostsFile
+}
+
+// ScanHostKeys fetches the host's public keys with ssh-keyscan, as
+// known_hosts lines. An ssh config alias is scanned at the address the
+// config resolves it to, which is the name ssh checks its keys under.
+func (m *Monitor) ScanHostKeys(host Host) ([]string, error) {
+	host, _, err := m.probeTarget(host)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("ssh-keyscan", "-T", strconv.Itoa(m.Config.SSHConnectTimeout), "-p", strconv.Itoa(host.Port), host.IP)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ssh-keyscan failed: %v", err)
+	}
+
+	var keys []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no host keys received from %s:%d", host.IP, host.Port)
+	}
+	return keys, nil
+}
+
+// TrustHost scans the host's keys and appends those not already present to
+// its known_hosts file, so it can be mounted with StrictHostKeyChecking=yes.
+// It returns how many keys were added and how many were already known.
+func (m *Monitor) TrustHost(host Host) (added, skipped int, err error) {
+	path := m.KnownHostsPath(host)
+	if path == "" {
+		return 0, 0, fmt.Errorf("no known_hosts file configured")
+	}
+
+	keys, err := m.ScanHostKeys(host)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	known, err := readKnownHosts(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if known[knownHostsKey(key)] {
+			skipped++
+			continue
+		}
+		known[knownHostsKey(key)] = true
+		missing = append(missing, key)
+	}
+	if len(missing) == 0 {
+		return 0, skipped, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, skipped, fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, skipped, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	for _, key := range missing {
+		if _, err := fmt.Fprintln(f, key); err != nil {
+			return added, skipped, fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		added++
+	}
+	return added, skipped, nil
+}
+
+// readKnownHosts returns the entries of a known_hosts file, keyed by
+// knownHostsKey. A missing file has no entries.
+func readKnownHosts(path string) (map[string]bool, error) {
+	known := make(map[string]bool)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return known, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			known[knownHostsKey(line)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return known, nil
+}
+
+// knownHostsKey reduces a known_hosts line to host, key type and key,
+// ignoring spacing and trailing comments.
+func knownHostsKey(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) > 3 {
+		fields = fields[:3]
+	}
+	return strings.Join(fields, " ")
+}
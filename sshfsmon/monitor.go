@@ -0,0 +1,325 @@
+// Package sshfsmon discovers remote hosts, mounts them over SSHFS and keeps
+// track of their state. The sshfs-connector command is a thin wrapper around
+// it; other programs can embed a Monitor directly.
+package sshfsmon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults used by DefaultConfig.
+const (
+	MOUNT_BASE    = "/root"
+	MOUNT_OPTIONS = "cache=no,attr_timeout=0,entry_timeout=0"
+	TIMEOUT       = 3
+	CONTROL_DIR   = "/run/sshfs-monitor"
+	MOUNT_TIMEOUT = 20
+
+	SSH_CONNECT_TIMEOUT = 5
+
+	MOUNT_PERM = 0755
+)
+
+// ErrMountTimeout is wrapped by the error of a mount that sshfs did not
+// finish within Config.MountTimeout.
+var ErrMountTimeout = errors.New("mount timed out")
+
+// ErrSessionLimit is wrapped by the error of a mount that sshd turned away,
+// typically because MaxStartups or MaxSessions was exceeded.
+var ErrSessionLimit = errors.New("sshd refused the session, too many concurrent connections (MaxStartups/MaxSessions)")
+
+// ALREADY_MOUNTED is the ExecutedCmd of a host that was found mounted and
+// needed no sshfs run.
+const ALREADY_MOUNTED = "already_mounted"
+
+// The HostResult.Recovery values of a stale mount: it came back when checked
+// again, it was remounted although ssh worked, or it was remounted with ssh
+// down as well.
+const (
+	RECOVERY_RELIST   = "relist"
+	RECOVERY_REMOUNT  = "remount"
+	RECOVERY_SSH_DOWN = "remount-ssh-down"
+)
+
+// Config holds the settings shared by every host of a Monitor.
+type Config struct {
+	MountBase         string        // base directory for relative mount paths
+	MountOptions      string        // default sshfs -o options
+	MountPerm         os.FileMode   // mode of the mount directories created
+	Timeout           int           // ping and hosts-URL fetch timeout, in seconds
+	SSHConnectTimeout int           // ssh ConnectTimeout for mounts and probes, in seconds
+	StrictHostKey     string        // ssh StrictHostKeyChecking: no, yes or accept-new
+	KnownHostsFile    string        // ssh UserKnownHostsFile for hosts without their own
+	SSHConfig         string        // ssh config file (-F) for sshfs and ssh; empty uses ~/.ssh/config
+	AllowOther        bool          // mount with allow_other
+	ControlMaster     bool          // share one ssh connection per host
+	ControlDir        string        // directory for the ControlMaster sockets
+	MountTimeout      time.Duration // kill sshfs after this long; 0 waits forever
+	MountTimeWarn     time.Duration // warn about mounts slower than this; 0 never does
+	SSHFSPath         string        // sshfs binary, looked up in PATH
+	SSHFSExtraArgs    []string      // appended to every sshfs command line
+	SSHFSOptions      []string      // -o options for every host, below its opts=
+	RclonePath        string        // rclone binary for backend=rclone hosts
+	SSHPath           string        // ssh binary for the remote probes
+
+	// HealthCheckTimeout fails a healthcheck= that runs longer; 0 waits
+	// forever.
+	HealthCheckTimeout time.Duration
+
+	// MountGrace is how long after it was made a mount that is slow to answer
+	// still counts as mounted rather than stale, as a fresh FUSE mount can
+	// take a moment to answer. It needs Monitor.MountedAt; 0 disables it.
+	MountGrace time.Duration
+
+	// RemountCooldown is how long after this Monitor mounted it a stale
+	// mount is left in place rather than cleared, so a mount on a marginal
+	// link gets time to settle instead of being remounted every cycle. 0
+	// disables it.
+	RemountCooldown time.Duration
+
+	// MountAs runs sshfs, and the unmount commands, as this local user
+	// through sudo, so the daemon itself never uses ssh credentials. The
+	// mounts belong to that user, and need AllowOther for anyone else,
+	// the daemon included, to see inside them. Empty runs them directly.
+	MountAs string
+
+	// MaxPerDestination caps concurrent mounts to one IP, such as a
+	// bastion fronting many hosts. 0 means no cap.
+	MaxPerDestination int
+
+	// Conservative never unmounts anything: stale mounts are reported, not
+	// cleared, and only empty mount points are mounted.
+	Conservative bool
+
+	// SafeRemount only clears a stale mount once ssh to the host is seen
+	// to work, so a remount can succeed; otherwise the prior mount is left
+	// in place and reported stale, in case it comes back by itself.
+	SafeRemount bool
+
+	// DeepCheck proves every mounted host end to end with CheckDeep, which
+	// writes to the mount. A host failing it is reported stale.
+	DeepCheck bool
+
+	// AllowShadow mounts over mount directories that have files in them,
+	// hiding the files while mounted. Without it such hosts are not
+	// mounted and their result is Shadowed.
+	AllowShadow bool
+
+	// DelayConnect mounts with sshfs' delay_connect and reconnect: sshfs
+	// returns once the mount is in place and connects on first use. Hosts
+	// not reachable yet, as while the network comes up at boot, are then
+	// mounted anyway, see AwaitingConnect.
+	DelayConnect bool
+
+	// RemoteInfo enables the ssh probes for a mounted host's RemoteFields.
+	// Servers that only allow sftp reject them.
+	RemoteInfo bool
+
+	// RemoteFields selects the remote info probes, in display order, from
+	// REMOTE_FIELDS.
+	RemoteFields []string
+
+	// ParallelRemoteInfo runs the remote info probes of a mounted host
+	// concurrently instead of one after another.
+	ParallelRemoteInfo bool
+
+	// UID and GID, numeric, own the mounted files for hosts without their
+	// own uid= or gid=. Empty leaves sshfs' default.
+	UID string
+	GID string
+
+	// HostsFormat is the hosts file format: "fields" (whitespace separated),
+	// "csv", "toml", or "auto" to pick csv or toml by the source's extension
+	// and fields otherwise.
+	HostsFormat string
+
+	// PingCount is how many probes a reachability check sends; any answer
+	// makes the host reachable.
+	PingCount int
+
+	// PingMethod is "icmp" (ping) or "tcp" (connect to the SSH port).
+	PingMethod string
+
+	// PostMountHook and PostUnmountHook are commands run in the background
+	// after a host is newly mounted or its mount torn down, see RunHook.
+	// Empty runs nothing.
+	PostMountHook   string
+	PostUnmountHook string
+
+	// HookTimeout bounds how long a hook may run; 0 means no limit.
+	HookTimeout time.Duration
+
+	// Debug logs the stderr of failed remote info probes.
+	Debug bool
+}
+
+// DefaultConfig returns the configuration the command line starts from.
+func DefaultConfig() Config {
+	return Config{
+		MountBase:          MOUNT_BASE,
+		MountOptions:       MOUNT_OPTIONS,
+		MountPerm:          MOUNT_PERM,
+		Timeout:            TIMEOUT,
+		SSHConnectTimeout:  SSH_CONNECT_TIMEOUT,
+		StrictHostKey:      "no",
+		ControlDir:         CONTROL_DIR,
+		MountTimeout:       MOUNT_TIMEOUT * time.Second,
+		HealthCheckTimeout: HEALTHCHECK_TIMEOUT,
+		SSHFSPath:          "sshfs",
+		RclonePath:         "rclone",
+		SSHPath:            "ssh",
+		RemoteInfo:         true,
+		ParallelRemoteInfo: true,
+		RemoteFields:       append([]string(nil), DEFAULT_REMOTE_FIELDS...),
+		HostsFormat:        "auto",
+		PingCount:          PING_COUNT,
+		PingMethod:         "icmp",
+		HookTimeout:        HOOK_TIMEOUT,
+	}
+}
+
+// Validate reports settings that would make every mount fail.
+func (c Config) Validate() error {
+	switch c.StrictHostKey {
+	case "no", "yes", "accept-new":
+	default:
+		return fmt.Errorf("--strict-host-key must be no, yes or accept-new, got %q", c.StrictHostKey)
+	}
+	switch c.HostsFormat {
+	case "auto", "fields", "csv", "toml":
+	default:
+		return fmt.Errorf("--hosts-format must be auto, fields, csv or toml, got %q", c.HostsFormat)
+	}
+	if !filepath.IsAbs(c.MountBase) {
+		return fmt.Errorf("--mount-base must be an absolute path, got %q", c.MountBase)
+	}
+	if c.MountPerm == 0 || c.MountPerm&^os.ModePerm != 0 {
+		return fmt.Errorf("--mount-perm must be an octal mode from 1 to 777, got %o", uint32(c.MountPerm))
+	}
+	if c.MaxPerDestination < 0 {
+		return fmt.Errorf("--max-per-destination must not be negative, got %d", c.MaxPerDestination)
+	}
+	for _, field := range c.RemoteFields {
+		if _, ok := REMOTE_FIELDS[field]; !ok {
+			return fmt.Errorf("--remote-fields: unknown field %q (known: hostname, uptime, mac, loadavg, diskfree)", field)
+		}
+	}
+	if c.PingCount < 1 {
+		return fmt.Errorf("--ping-count must be at least 1, got %d", c.PingCount)
+	}
+	switch c.PingMethod {
+	case "icmp", "tcp":
+	default:
+		return fmt.Errorf("--ping-method must be icmp or tcp, got %q", c.PingMethod)
+	}
+	if c.MountTimeWarn < 0 {
+		return fmt.Errorf("--max-mount-time-warn must not be negative, got %s", c.MountTimeWarn)
+	}
+	if c.MountGrace < 0 {
+		return fmt.Errorf("--mount-grace must not be negative, got %s", c.MountGrace)
+	}
+	for _, list := range c.SSHFSOptions {
+		for _, opt := range strings.Split(list, ",") {
+			if key, _, _ := strings.Cut(strings.TrimSpace(opt), "="); key == "port" {
+				return fmt.Errorf("--sshfs-opt cannot set port, it comes from the hosts file")
+			}
+		}
+	}
+	if c.SSHConfig != "" {
+		if _, err := os.Stat(c.SSHConfig); err != nil {
+			return fmt.Errorf("--ssh-config: %v", err)
+		}
+	}
+	if c.RemountCooldown < 0 {
+		return fmt.Errorf("--remount-cooldown must not be negative, got %s", c.RemountCooldown)
+	}
+	if c.MountAs != "" && !c.AllowOther {
+		return fmt.Errorf("--mount-as needs --allow-other, or the daemon cannot check the mounts %s makes", c.MountAs)
+	}
+	if c.SSHConnectTimeout <= 0 {
+		return fmt.Errorf("--ssh-connect-timeout must be a positive number of seconds, got %d", c.SSHConnectTimeout)
+	}
+	return nil
+}
+
+// ResolveBinaries looks up SSHFSPath, SSHPath and the hooks that are set and
+// replaces them with the paths found, so a missing binary is reported up front rather than on
+// every mount. With MountAs it also runs CheckMountAs.
+func (c *Config) ResolveBinaries() error {
+	for _, binary := range []struct {
+		flag string
+		path *string
+	}{
+		{"--sshfs-path", &c.SSHFSPath},
+		{"--ssh-path", &c.SSHPath},
+		{"--post-mount-hook", &c.PostMountHook},
+		{"--post-unmount-hook", &c.PostUnmountHook},
+	} {
+		if *binary.path == "" {
+			continue
+		}
+		resolved, err := exec.LookPath(*binary.path)
+		if err != nil {
+			return fmt.Errorf("%s: %s not found: %v", binary.flag, *binary.path, err)
+		}
+		*binary.path = resolved
+	}
+	return c.CheckMountAs()
+}
+
+// Monitor mounts and probes hosts according to its Config.
+type Monitor struct {
+	Config Config
+
+	// Log receives routine events about a host, such as reachability and
+	// mount results. Nil discards them.
+	Log func(host Host, message string)
+
+	// Notice receives events an interactive user should see as well, such
+	// as stale endpoint clean-up. Nil discards them.
+	Notice func(message string)
+
+	// MayMount, when set, is asked before a reachable host that is not
+	// mounted gets mounted; false holds the mount off for now.
+	MayMount func(host Host) bool
+
+	// MayClear, when set, is asked before a stale mount is torn down;
+	// false leaves it in place and reports the host as stale.
+	MayClear func(host Host) bool
+
+	// MountedAt, when set, tells when the host's current mount was made,
+	// or the zero time if not known, for Config.MountGrace.
+	MountedAt func(host Host) time.Time
+
+	// Run, when set, runs the mount and ping commands in place of
+	// executing them, for tests faking sshfs. Nil runs them with
+	// ExecRunner.
+	Run CommandRunner
+
+	hooks  *sync.WaitGroup // shared with copies of the Monitor
+	mounts *mountLog       // likewise
+	busy   *inFlight       // likewise
+}
+
+func New(config Config) *Monitor {
+	return &Monitor{Config: config, hooks: &sync.WaitGroup{}, mounts: &mountLog{}, busy: &inFlight{}}
+}
+
+func (m *Monitor) logf(host Host, format string, args ...interface{}) {
+	if m.Log != nil {
+		m.Log(host, fmt.Sprintf(format, args...))
+	}
+}
+
+func (m *Monitor) noticef(format string, args ...interface{}) {
+	if m.Notice != nil {
+		m.Notice(fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,712 @@
+package sshfsmon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// STAT_TIMEOUT is how long a stat of a mount point may take before the
+// mount counts as stale; a wedged sshfs never answers at all.
+const STAT_TIMEOUT = 3 * time.Second
+
+// endpointStale reports whether mountPoint exists but the backend does not
+// find the mount healthy, as happens when the sshfs behind it has died or
+// hangs. Whether it exists is read from the parent directory, which lists
+// the entry without asking the dead mount.
+func endpointStale(mounter Mounter, mountPoint string) bool {
+	if mounter.IsHealthy(mountPoint) {
+		return false
+	}
+	parent, err := os.Open(filepath.Dir(mountPoint))
+	if err != nil {
+		return false
+	}
+	defer parent.Close()
+	names, _ := parent.Readdirnames(-1)
+	for _, name := range names {
+		if name == filepath.Base(mountPoint) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClearStaleEndpoint unmounts mountPoint if it exists but does not answer,
+// trying the platform's unmount commands in turn. In conservative mode
+// it only reports the stale endpoint.
+func (m *Monitor) ClearStaleEndpoint(mountPoint string) error {
+	m.clearStale(mountPoint, m.Mounter(Host{}))
+	return nil
+}
+
+// clearStale does the work of ClearStaleEndpoint, unmounting through
+// mounter, and reports whether it tried to unmount.
+func (m *Monitor) clearStale(mountPoint string, mounter Mounter) bool {
+	if !endpointStale(mounter, mountPoint) {
+		return false
+	}
+	if m.Config.Conservative {
+		m.noticef("Stale SSHFS endpoint at %s left in place (conservative mode)", mountPoint)
+		return false
+	}
+	if reason := m.cooldownHold(mountPoint); reason != "" {
+		m.noticef("Stale SSHFS endpoint at %s left in place: %s", mountPoint, reason)
+		return false
+	}
+	m.noticef("Detected stale SSHFS endpoint at %s, clearing...", mountPoint)
+
+	mounter.Unmount(mountPoint)
+
+	time.Sleep(time.Second)
+
+	// Verify cleanup
+	if mounter.IsHealthy(mountPoint) {
+		m.noticef("Successfully cleared stale endpoint: %s", mountPoint)
+	} else {
+		m.noticef("Warning: Could not fully clear stale endpoint: %s", mountPoint)
+	}
+	return true
+}
+
+// clearStaleHost clears the host's stale mount, if any, and runs the
+// post-unmount hook when it did.
+func (m *Monitor) clearStaleHost(host Host) {
+	if m.clearStale(host.MountPath, m.Mounter(host)) {
+		m.RunHook(m.Config.PostUnmountHook, "post-unmount", host)
+	}
+}
+
+// mountFailureHints maps well-known sshfs/fusermount/ssh messages to advice
+// on fixing the underlying problem.
+var mountFailureHints = []struct {
+	pattern string
+	hint    string
+}{
+	{"option allow_other only allowed if", "add user_allow_other to /etc/fuse.conf"},
+	{"failed to open /dev/fuse", "add the user to the fuse group (usermod -aG fuse USER) or check /dev/fuse permissions"},
+	{"fuse: device not found", "load the fuse kernel module (modprobe fuse)"},
+	{"mountpoint is not empty", "the mount directory contains files; empty it or use the nonempty option"},
+	{"Permission denied (publickey", "the remote rejected the SSH key; check authorized_keys for this user"},
+	{"read: Connection reset by peer", "sshd dropped the connection; check the remote sshd logs and MaxStartups"},
+	{"remote host has disconnected", "the SSH session ended before SFTP started; check the login works with plain ssh and that the sftp subsystem is enabled"},
+	{"Connection refused", "nothing is listening on the SSH port; check sshd is running and the port is right"},
+	{"No such file or directory", "the remote directory does not exist"},
+}
+
+// sessionLimitPatterns are ssh messages of a server turning connections
+// away because too many are open at once.
+var sessionLimitPatterns = []string{
+	"ssh_exchange_identification: Connection closed by remote host",
+	"kex_exchange_identification: Connection closed by remote host",
+	"Too many authentication failures",
+}
+
+// STDERR_LOG_LINES is how many trailing lines of a failed command's stderr
+// go into the log.
+const STDERR_LOG_LINES = 3
+
+// stderrTail returns the last n non-empty lines of stderr joined into one
+// log-friendly line.
+func stderrTail(stderr string, n int) string {
+	var tail []string
+	lines := strings.Split(stderr, "\n")
+	for i := len(lines) - 1; i >= 0 && len(tail) < n; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			tail = append([]string{line}, tail...)
+		}
+	}
+	return strings.Join(tail, " | ")
+}
+
+// mountError turns a failed sshfs run into an error carrying the last line
+// sshfs printed and, where the failure is a known one, a remediation hint.
+func (m *Monitor) mountError(host Host, err error, stderr string) error {
+	if strings.Contains(stderr, "Host key verification failed") {
+		// With strict checking on this is a trust problem, not a
+		// connectivity one, so say so
+		return fmt.Errorf("host key verification failed for %s (StrictHostKeyChecking=%s); add its key to known_hosts or use --strict-host-key=accept-new", host.IP, m.Config.StrictHostKey)
+	}
+
+	var lastLine string
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			lastLine = line
+			break
+		}
+	}
+	if lastLine == "" {
+		return fmt.Errorf("failed to mount: %v", err)
+	}
+
+	for _, pattern := range sessionLimitPatterns {
+		if strings.Contains(stderr, pattern) {
+			return fmt.Errorf("failed to mount: %s (%w)", lastLine, ErrSessionLimit)
+		}
+	}
+
+	for _, h := range mountFailureHints {
+		if strings.Contains(stderr, h.pattern) {
+			return fmt.Errorf("failed to mount: %s (hint: %s)", lastLine, h.hint)
+		}
+	}
+	return fmt.Errorf("failed to mount: %s", lastLine)
+}
+
+// MountHost checks that the host is reachable and makes sure its mount is
+// in place, mounting it if needed. A mount that is up then gets the deep
+// check, if enabled, and the host's healthcheck.
+func (m *Monitor) MountHost(host Host) HostResult {
+	return m.mountChecked(m.checkReachable(host))
+}
+
+// mountChecked continues MountHost from a result checkReachable filled in,
+// possibly for another mount of the same server.
+func (m *Monitor) mountChecked(result HostResult) HostResult {
+	if !m.claim(result.Host.MountPath) {
+		result.Error = ErrInFlight
+		m.logf(result.Host, "Not mounting %s: %v", result.Host.MountPath, ErrInFlight)
+		return result
+	}
+	defer m.release(result.Host.MountPath)
+	result = m.mountHost(result)
+	m.CheckDeep(&result)
+	if result.Mounted {
+		m.CheckReadOnly(&result)
+		m.CheckHealth(&result)
+	}
+	return result
+}
+
+// checkReachable pings the host and, if it answers, knocks on its SSH port.
+func (m *Monitor) checkReachable(host Host) HostResult {
+	start := time.Now()
+
+	result := HostResult{
+		Host: host,
+	}
+
+	// Resolve an ssh config alias once, for the ping and the port knock
+	target, viaSSH, err := m.probeTarget(host)
+	if err != nil {
+		result.Error = err
+		result.CheckTime = time.Since(start)
+		m.logf(host, "Host %s not reachable: %v", host.IP, err)
+		return result
+	}
+
+	// Check if host is reachable
+	reachable, rtt := m.pingTarget(host, target, viaSSH)
+	result.Reachable = reachable
+	result.PingTime = time.Since(start)
+	result.CheckTime = time.Since(start)
+
+	if !reachable {
+		m.logf(host, "Host %s not reachable", host.IP)
+		return result
+	}
+
+	m.logf(host, "Host %s reachable (ping: %s)", host.IP, rtt)
+
+	// A host can be up with sshd down; tell the two apart. Through a jump
+	// host, or an alias only ssh can reach, the reachability check already
+	// reached sshd, and an rclone remote need not speak ssh at all.
+	result.SSHReachable = host.ProxyJump != "" || viaSSH || host.Backend == BACKEND_RCLONE ||
+		SSHPortOpen(target, m.Config.Timeout)
+	if !result.SSHReachable {
+		result.Error = fmt.Errorf("SSH port %d not accepting connections", host.Port)
+		m.logf(host, "Host %s reachable but SSH port %d is closed", host.IP, host.Port)
+	}
+	return result
+}
+
+func (m *Monitor) mountHost(result HostResult) HostResult {
+	host := result.Host
+	if !result.Reachable || !result.SSHReachable {
+		if m.Config.DelayConnect {
+			return m.mountDelayed(result)
+		}
+		return result
+	}
+
+	// Clear stale endpoints, unless the mount is fresh enough for the
+	// grace period, conservative mode or MayClear says to report them and
+	// stop, or the mount comes back by itself
+	if endpointStale(m.Mounter(host), host.MountPath) {
+		if m.InGrace(host) {
+			return m.graceResult(result)
+		}
+		if reason := m.staleHold(host); reason != "" {
+			return m.staleResult(result, reason)
+		}
+		if m.recoverStale(&result) {
+			return result
+		}
+	}
+	m.clearStaleHost(host)
+
+	// Create mount directory if it doesn't exist
+	if err := m.prepareMountDir(host); err != nil {
+		result.Error = err
+		return result
+	}
+
+	// Check if already mounted
+	if IsMountPoint(host.MountPath) {
+		// Verify mount is accessible
+		if m.Mounter(host).IsHealthy(host.MountPath) {
+			result.Mounted = true
+			result.ExecutedCmd = ALREADY_MOUNTED
+			m.logf(host, "Mount verified: %s", host.MountPath)
+			infoStart := time.Now()
+			result.RemoteInfo = m.remoteInfo(host)
+			result.RemoteInfoTime = time.Since(infoStart)
+			return result
+		}
+		// Stale mount, clean it
+		if m.InGrace(host) {
+			return m.graceResult(result)
+		}
+		if reason := m.staleHold(host); reason != "" {
+			return m.staleResult(result, reason)
+		}
+		if m.recoverStale(&result) {
+			return result
+		}
+		m.clearStaleHost(host)
+	}
+
+	if m.MayMount != nil && !m.MayMount(host) {
+		result.Error = fmt.Errorf("mount held off until the host has been reachable longer")
+		return result
+	}
+	if m.checkShadow(&result) {
+		return result
+	}
+
+	// Create the remote directory first if the host asks for it
+	if host.MkRemote {
+		if err := m.CreateRemoteDir(host); err != nil {
+			result.Error = err
+			m.logf(host, "Failed to prepare %s:%d: %v", host.IP, host.Port, err)
+			return result
+		}
+	}
+
+	result = m.runMount(result)
+	if !result.Mounted {
+		return result
+	}
+	m.RunHook(m.Config.PostMountHook, "post-mount", host)
+
+	// Get remote info after successful mount
+	infoStart := time.Now()
+	result.RemoteInfo = m.remoteInfo(host)
+	result.RemoteInfoTime = time.Since(infoStart)
+
+	return result
+}
+
+// prepareMountDir creates the host's mount directory if needed and hands
+// it to Config.MountAs.
+func (m *Monitor) prepareMountDir(host Host) error {
+	if err := os.MkdirAll(host.MountPath, m.Config.MountPerm); err != nil {
+		return fmt.Errorf("failed to create mount directory: %v", err)
+	}
+	if err := m.chownMountDir(host.MountPath); err != nil {
+		return fmt.Errorf("failed to hand mount directory to %s: %v", m.Config.MountAs, err)
+	}
+	return nil
+}
+
+// runMount runs the host's backend to mount it, filling in the command
+// line, the time it took and the outcome.
+func (m *Monitor) runMount(result HostResult) HostResult {
+	host := result.Host
+	mountStart := time.Now()
+
+	ctx := context.Background()
+	if m.Config.MountTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Config.MountTimeout)
+		defer cancel()
+	}
+	cmdline, stderr, err := m.Mounter(host).Mount(ctx, host)
+	result.ExecutedCmd = cmdline
+	result.MountTime = time.Since(mountStart)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		result.Error = fmt.Errorf("%w after %v", ErrMountTimeout, m.Config.MountTimeout)
+		m.logf(host, "Mount timed out: %s:%d (%.6fs)", host.IP, host.Port, result.MountTime.Seconds())
+		// Clean up whatever half-mount the killed mount command left behind
+		m.clearStaleHost(host)
+		return result
+	}
+	if err != nil {
+		result.Error = m.mountError(host, err, stderr)
+		detail := stderrTail(stderr, STDERR_LOG_LINES)
+		if detail == "" {
+			detail = err.Error()
+		}
+		m.logf(host, "Failed to mount: %s:%d (%.6fs): %s", host.IP, host.Port, result.MountTime.Seconds(), detail)
+		return result
+	}
+
+	result.Mounted = true
+	m.noteMounted(host.MountPath)
+	m.logf(host, "Successfully mounted: %s:%d -> %s (%.6fs)", host.IP, host.Port, host.MountPath, result.MountTime.Seconds())
+	if m.SlowMount(result) {
+		m.logf(host, "WARN: Slow mount: %s:%d took %.3fs, over %s", host.IP, host.Port, result.MountTime.Seconds(), m.Config.MountTimeWarn)
+	}
+	return result
+}
+
+// InGrace reports whether the host's mount is younger than
+// Config.MountGrace, so failing to answer a stat is not yet taken as stale.
+func (m *Monitor) InGrace(host Host) bool {
+	if m.Config.MountGrace <= 0 || m.MountedAt == nil || !IsMountPoint(host.MountPath) {
+		return false
+	}
+	mountedAt := m.MountedAt(host)
+	return !mountedAt.IsZero() && time.Since(mountedAt) < m.Config.MountGrace
+}
+
+// graceResult reports a fresh mount that is slow to answer as mounted.
+func (m *Monitor) graceResult(result HostResult) HostResult {
+	result.Mounted = true
+	result.ExecutedCmd = ALREADY_MOUNTED
+	m.logf(result.Host, "Mount %s slow to answer but within its %s grace period, leaving it", result.Host.MountPath, m.Config.MountGrace)
+	return result
+}
+
+// SlowMount reports whether the result is of a mount that succeeded but took
+// longer than Config.MountTimeWarn, an early sign of a degrading link.
+func (m *Monitor) SlowMount(result HostResult) bool {
+	return m.Config.MountTimeWarn > 0 && result.Mounted && result.ExecutedCmd != ALREADY_MOUNTED &&
+		result.MountTime > m.Config.MountTimeWarn
+}
+
+// staleHold returns why a stale mount of the host must be left in place, or
+// "" if it may be cleared.
+func (m *Monitor) staleHold(host Host) string {
+	if m.Config.Conservative {
+		return "not clearing it in conservative mode"
+	}
+	if reason := m.cooldownHold(host.MountPath); reason != "" {
+		m.logf(host, "Stale mount %s: %s", host.MountPath, reason)
+		return reason
+	}
+	if m.MayClear != nil && !m.MayClear(host) {
+		return "not clearing it until the host has failed longer"
+	}
+	return ""
+}
+
+// RELIST_TRIES is how often a stale mount whose ssh connection still works
+// is checked again, RELIST_DELAY apart, before it is remounted.
+const (
+	RELIST_TRIES = 2
+	RELIST_DELAY = time.Second
+)
+
+// recoverStale tries the gentle way out of a stale mount before the unmount
+// ladder. If ssh to the host still works, the connection is fine and only
+// the FUSE side is confused, which often passes, so the mount is checked
+// again a few times. The path taken goes into result.Recovery; it reports
+// whether the result is final: the mount came back, or with SafeRemount it
+// stays in place because ssh fails.
+func (m *Monitor) recoverStale(result *HostResult) bool {
+	host := result.Host
+	if host.Backend == BACKEND_RCLONE {
+		// No ssh to ask; go straight to remounting
+		result.Recovery = RECOVERY_REMOUNT
+		return false
+	}
+	if !m.SSHHealthy(host) {
+		if m.Config.SafeRemount {
+			m.logf(host, "Stale mount %s: ssh to %s fails, so a remount could not succeed; prior mount left intact", host.MountPath, host.IP)
+			*result = m.staleResult(*result, fmt.Sprintf("ssh to %s fails, keeping the prior mount (safe remount)", host.IP))
+			return true
+		}
+		result.Recovery = RECOVERY_SSH_DOWN
+		m.logf(host, "Stale mount %s: ssh to %s fails too, remounting", host.MountPath, host.IP)
+		return false
+	}
+	for try := 0; try < RELIST_TRIES; try++ {
+		time.Sleep(RELIST_DELAY)
+		if m.Mounter(host).IsHealthy(host.MountPath) {
+			result.Recovery = RECOVERY_RELIST
+			result.Mounted = true
+			result.ExecutedCmd = ALREADY_MOUNTED
+			m.logf(host, "Stale mount %s recovered without remounting, ssh to %s is healthy", host.MountPath, host.IP)
+			infoStart := time.Now()
+			result.RemoteInfo = m.remoteInfo(host)
+			result.RemoteInfoTime = time.Since(infoStart)
+			return true
+		}
+	}
+	result.Recovery = RECOVERY_REMOUNT
+	m.logf(host, "Stale mount %s: ssh to %s is healthy but the mount stays stale, remounting", host.MountPath, host.IP)
+	return false
+}
+
+// staleResult marks a result as a stale mount that is left in place, for
+// the given reason.
+func (m *Monitor) staleResult(result HostResult, reason string) HostResult {
+	result.Stale = true
+	result.Error = fmt.Errorf("mount at %s is stale; %s", result.Host.MountPath, reason)
+	m.logf(result.Host, "Stale mount left in place: %s", result.Host.MountPath)
+	return result
+}
+
+// ProcessHostsParallel runs MountHost for every host concurrently and
+// returns the results in host order. At most MaxPerDestination mounts run
+// against one IP at a time, and hosts that sshd turned away for having too
+// many sessions open are retried one at a time per IP.
+//
+// Hosts sharing a Destination are mounted as a group: the server is pinged
+// once for the group (per jump host), with ControlMaster the first one
+// mounts alone, opening the connection the rest then ride, and the remote
+// info is probed once per group rather than once per mount.
+//
+// Hosts with after= are only mounted once the hosts they name have mounted,
+// and skipped with ErrUnmetDependency when those did not.
+func (m *Monitor) ProcessHostsParallel(hosts []Host) []HostResult {
+	if layers := dependencyLayers(hosts); len(layers) > 1 {
+		return m.processInLayers(hosts, layers)
+	}
+	return m.processHosts(hosts)
+}
+
+// processHosts is ProcessHostsParallel for hosts that do not wait for each
+// other.
+func (m *Monitor) processHosts(hosts []Host) []HostResult {
+	var wg sync.WaitGroup
+	results := make([]HostResult, len(hosts))
+
+	limits := make(map[string]chan struct{})
+	if m.Config.MaxPerDestination > 0 {
+		for _, host := range hosts {
+			if limits[host.IP] == nil {
+				limits[host.IP] = make(chan struct{}, m.Config.MaxPerDestination)
+			}
+		}
+	}
+
+	groups := make(map[string][]int)
+	for i, host := range hosts {
+		groups[host.Destination()] = append(groups[host.Destination()], i)
+	}
+
+	// Remote info waits until every mount of a group is done
+	mounter := *m
+	mounter.Config.RemoteInfo = false
+	mount := func(index int, reach *HostResult) {
+		h := hosts[index]
+		if limit := limits[h.IP]; limit != nil {
+			limit <- struct{}{}
+			defer func() { <-limit }()
+		}
+		if reach == nil || reach.Host.ProxyJump != h.ProxyJump {
+			results[index] = mounter.MountHost(h)
+			return
+		}
+		shared := *reach
+		shared.Host = h
+		results[index] = mounter.mountChecked(shared)
+	}
+
+	for _, indexes := range groups {
+		wg.Add(1)
+		go func(indexes []int) {
+			defer wg.Done()
+			var reach *HostResult
+			if len(indexes) > 1 {
+				first := mounter.checkReachable(hosts[indexes[0]])
+				reach = &first
+			}
+			if m.Config.ControlMaster && len(indexes) > 1 {
+				mount(indexes[0], reach)
+				indexes = indexes[1:]
+			}
+			var rest sync.WaitGroup
+			for _, i := range indexes {
+				rest.Add(1)
+				go func(index int) {
+					defer rest.Done()
+					mount(index, reach)
+				}(i)
+			}
+			rest.Wait()
+		}(indexes)
+	}
+
+	wg.Wait()
+	limited := make(map[string]bool)
+	for _, result := range results {
+		if errors.Is(result.Error, ErrSessionLimit) {
+			limited[result.Host.Destination()] = true
+		}
+	}
+	mounter.retrySessionLimited(results)
+	m.shareRemoteInfo(results, groups, limited)
+	return results
+}
+
+// CheckHostsParallel is the reachability half of ProcessHostsParallel: every
+// host is pinged and its SSH port knocked on, once per server as there,
+// without touching mount directories, sshfs or the remote info probes. The
+// mount fields of the results stay zero.
+func (m *Monitor) CheckHostsParallel(hosts []Host) []HostResult {
+	results := make([]HostResult, len(hosts))
+
+	// A jump host changes how a server is reached
+	groups := make(map[string][]int)
+	for i, host := range hosts {
+		key := host.Destination() + " " + host.ProxyJump
+		groups[key] = append(groups[key], i)
+	}
+
+	var wg sync.WaitGroup
+	for _, indexes := range groups {
+		wg.Add(1)
+		go func(indexes []int) {
+			defer wg.Done()
+			reach := m.checkReachable(hosts[indexes[0]])
+			for _, i := range indexes {
+				results[i] = reach
+				results[i].Host = hosts[i]
+			}
+		}(indexes)
+	}
+	wg.Wait()
+	return results
+}
+
+// shareRemoteInfo probes the remote info of each group of results through
+// its first mounted host and hands it to every mounted host of the group.
+// Groups that ran into sshd's session limit are probed one at a time.
+func (m *Monitor) shareRemoteInfo(results []HostResult, groups map[string][]int, limited map[string]bool) {
+	if !m.Config.RemoteInfo {
+		return
+	}
+	serial := *m
+	serial.Config.ParallelRemoteInfo = false
+
+	var wg sync.WaitGroup
+	for destination, indexes := range groups {
+		var mounted []int
+		for _, i := range indexes {
+			if results[i].Mounted {
+				mounted = append(mounted, i)
+			}
+		}
+		if len(mounted) == 0 {
+			continue
+		}
+		prober := m
+		if limited[destination] {
+			prober = &serial
+		}
+		wg.Add(1)
+		go func(mounted []int) {
+			defer wg.Done()
+			first := &results[mounted[0]]
+			infoStart := time.Now()
+			first.RemoteInfo = prober.remoteInfo(first.Host)
+			first.RemoteInfoTime = time.Since(infoStart)
+			for _, i := range mounted[1:] {
+				results[i].RemoteInfo = first.RemoteInfo
+				results[i].RemoteInfoTime = 0
+			}
+		}(mounted)
+	}
+	wg.Wait()
+}
+
+// SESSION_RETRIES is how often a mount turned away by sshd is retried, with
+// a growing SESSION_BACKOFF before each round.
+const (
+	SESSION_RETRIES = 3
+	SESSION_BACKOFF = 2 * time.Second
+)
+
+// retrySessionLimited retries the results that failed with ErrSessionLimit,
+// serialized per destination IP and with the remote info probes run one at
+// a time, so a busy sshd sees one session from us at a time.
+func (m *Monitor) retrySessionLimited(results []HostResult) {
+	byIP := make(map[string][]int)
+	for i, result := range results {
+		if errors.Is(result.Error, ErrSessionLimit) {
+			byIP[result.Host.IP] = append(byIP[result.Host.IP], i)
+		}
+	}
+	if len(byIP) == 0 {
+		return
+	}
+
+	serial := *m
+	serial.Config.ParallelRemoteInfo = false
+
+	var wg sync.WaitGroup
+	for ip, indexes := range byIP {
+		wg.Add(1)
+		go func(ip string, indexes []int) {
+			defer wg.Done()
+			for attempt := 1; attempt <= SESSION_RETRIES && len(indexes) > 0; attempt++ {
+				m.logf(results[indexes[0]].Host, "Too many sessions at %s, retrying %d mount(s) one at a time (attempt %d)", ip, len(indexes), attempt)
+				time.Sleep(time.Duration(attempt) * SESSION_BACKOFF)
+				var still []int
+				for _, i := range indexes {
+					results[i] = serial.MountHost(results[i].Host)
+					if errors.Is(results[i].Error, ErrSessionLimit) {
+						still = append(still, i)
+					}
+				}
+				indexes = still
+			}
+		}(ip, indexes)
+	}
+	wg.Wait()
+}
+
+// Status classifies a result as ONLINE, STALE, DEGRADED, READONLY-VIOLATION,
+// SHADOWED, CONN-ERR, SSH-DOWN, OFFLINE or SKIPPED. It re-checks the
+// mountpoint, so a mount that died since the result was taken shows as STALE.
+func (m *Monitor) Status(result HostResult) string {
+	if result.Skipped {
+		return "SKIPPED"
+	}
+	if !result.Reachable {
+		return "OFFLINE"
+	}
+	if !result.SSHReachable {
+		return "SSH-DOWN"
+	}
+	if result.Stale {
+		return "STALE"
+	}
+	if result.Shadowed {
+		return "SHADOWED"
+	}
+	if !result.Mounted {
+		return "CONN-ERR"
+	}
+	if result.ReadOnlyViolation {
+		return "READONLY-VIOLATION"
+	}
+	if !result.HealthOK {
+		return "DEGRADED"
+	}
+	// Check if mount is still accessible
+	if !IsMountPoint(result.Host.MountPath) {
+		return "STALE"
+	}
+	return "ONLINE"
+}
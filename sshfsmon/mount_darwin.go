@@ -0,0 +1,57 @@
+package sshfsmon
+
+import (
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// darwinPlatform covers macOS with macFUSE, which has no fusermount or
+// mountpoint, and whose ping takes its timeout with -t (-W is milliseconds).
+type darwinPlatform struct{}
+
+var currentPlatform platform = darwinPlatform{}
+
+func (darwinPlatform) pingArgs(host string, count, timeout int) []string {
+	// -t bounds the whole run, and pings go out a second apart
+	return []string{"-c", strconv.Itoa(count), "-t", strconv.Itoa(timeout + count - 1), host}
+}
+
+func (darwinPlatform) unmountLadder(mountPoint string) [][]string {
+	return [][]string{
+		{"umount", mountPoint},
+		{"diskutil", "unmount", mountPoint},
+		{"diskutil", "unmount", "force", mountPoint},
+	}
+}
+
+// A mount point sits on a different device than its parent directory.
+func (darwinPlatform) isMountPoint(path string) bool {
+	var self, parent syscall.Stat_t
+	if err := syscall.Stat(path, &self); err != nil {
+		return false
+	}
+	if err := syscall.Stat(filepath.Dir(path), &parent); err != nil {
+		return false
+	}
+	return self.Dev != parent.Dev
+}
+
+// The filesystem type of what is mounted at path, "" if nothing is.
+func (p darwinPlatform) mountType(path string) string {
+	if !p.isMountPoint(path) {
+		return ""
+	}
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return ""
+	}
+	var name []byte
+	for _, c := range fs.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	return string(name)
+}
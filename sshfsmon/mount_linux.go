@@ -0,0 +1,126 @@
+package sshfsmon
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+type linuxPlatform struct{}
+
+var currentPlatform platform = linuxPlatform{}
+
+func (linuxPlatform) pingArgs(host string, count, timeout int) []string {
+	args := []string{"-c", strconv.Itoa(count), "-W", strconv.Itoa(timeout)}
+	if count > 1 {
+		// 0.2s is the shortest interval ping allows unprivileged users
+		args = append(args, "-i", "0.2")
+	}
+	return append(args, host)
+}
+
+// Try fusermount first, then umount, then a lazy umount
+func (linuxPlatform) unmountLadder(mountPoint string) [][]string {
+	return [][]string{
+		{"fusermount", "-u", mountPoint},
+		{"umount", mountPoint},
+		{"umount", "-l", mountPoint},
+	}
+}
+
+// mountpointBin is the mountpoint binary, "" when it is not installed, as on
+// minimal images.
+var mountpointBin = sync.OnceValue(func() string {
+	path, _ := exec.LookPath("mountpoint")
+	return path
+})
+
+// Use mountpoint where installed; otherwise read the kernel's mount table,
+// and if even that is unavailable, compare devices like on macOS.
+func (linuxPlatform) isMountPoint(path string) bool {
+	if bin := mountpointBin(); bin != "" {
+		return exec.Command(bin, "-q", path).Run() == nil
+	}
+	if _, mounted, err := mountInfo(path); err == nil {
+		return mounted
+	}
+	return deviceBoundary(path)
+}
+
+func (linuxPlatform) mountType(path string) string {
+	fsType, _, _ := mountInfo(path)
+	return fsType
+}
+
+// MOUNTINFO lists the mounts of our mount namespace.
+const MOUNTINFO = "/proc/self/mountinfo"
+
+// mountInfo looks path up in MOUNTINFO and returns the filesystem type of
+// what is mounted there, e.g. fuse.sshfs. The last entry wins, as a later
+// mount hides an earlier one on the same path.
+func mountInfo(path string) (fsType string, mounted bool, err error) {
+	f, err := os.Open(MOUNTINFO)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// id parent major:minor root mount_point options [optional...] - type source super_options
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || unescapeMountPath(fields[4]) != path {
+			continue
+		}
+		mounted = true
+		fsType = ""
+		for i := 5; i < len(fields)-1; i++ {
+			if fields[i] == "-" {
+				fsType = fields[i+1]
+				break
+			}
+		}
+	}
+	return fsType, mounted, scanner.Err()
+}
+
+// unescapeMountPath undoes the octal escapes (\040 for a space) the kernel
+// writes for whitespace and backslashes in mount paths.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// deviceBoundary reports whether path sits on a different device than its
+// parent directory, which is true of mount points.
+func deviceBoundary(path string) bool {
+	var self, parent syscall.Stat_t
+	if err := syscall.Stat(path, &self); err != nil {
+		return false
+	}
+	if err := syscall.Stat(filepath.Dir(path), &parent); err != nil {
+		return false
+	}
+	return self.Dev != parent.Dev
+}
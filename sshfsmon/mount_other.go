@@ -0,0 +1,43 @@
+//go:build !linux && !darwin
+
+package sshfsmon
+
+import (
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// genericPlatform covers the other Unixes, such as the BSDs, with the
+// commands they share: plain umount, and a ping taking -c and -t.
+type genericPlatform struct{}
+
+var currentPlatform platform = genericPlatform{}
+
+func (genericPlatform) pingArgs(host string, count, timeout int) []string {
+	return []string{"-c", strconv.Itoa(count), "-t", strconv.Itoa(timeout + count - 1), host}
+}
+
+func (genericPlatform) unmountLadder(mountPoint string) [][]string {
+	return [][]string{
+		{"umount", mountPoint},
+		{"umount", "-f", mountPoint},
+	}
+}
+
+// A mount point sits on a different device than its parent directory.
+func (genericPlatform) isMountPoint(path string) bool {
+	var self, parent syscall.Stat_t
+	if err := syscall.Stat(path, &self); err != nil {
+		return false
+	}
+	if err := syscall.Stat(filepath.Dir(path), &parent); err != nil {
+		return false
+	}
+	return self.Dev != parent.Dev
+}
+
+// The filesystem type is not looked up here; "" means unknown.
+func (genericPlatform) mountType(path string) string {
+	return ""
+}
@@ -0,0 +1,216 @@
+package sshfsmon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testHost is a host mounting under dir.
+func testHost(dir, name string) Host {
+	return Host{
+		IP:        "127.0.0.1",
+		MountPath: filepath.Join(dir, name),
+		Port:      22,
+		RemoteDir: "/root",
+		Username:  "root",
+	}
+}
+
+func TestRunMountTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.MountTimeout = 50 * time.Millisecond
+	m := New(config)
+
+	var gotCmd string
+	m.Run = func(ctx context.Context, name string, args ...string) (string, string, error) {
+		gotCmd = name
+		// A wedged sshfs: only the deadline ends it
+		select {
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		case <-time.After(5 * time.Second):
+			return "", "", nil
+		}
+	}
+
+	start := time.Now()
+	result := m.runMount(HostResult{Host: testHost(t.TempDir(), "a")})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("runMount waited %s, past the %s mount timeout", elapsed, config.MountTimeout)
+	}
+	if gotCmd != config.SSHFSPath {
+		t.Errorf("ran %q, want %q", gotCmd, config.SSHFSPath)
+	}
+	if result.Mounted {
+		t.Error("a timed out mount reported mounted")
+	}
+	if !errors.Is(result.Error, ErrMountTimeout) {
+		t.Errorf("error %v, want ErrMountTimeout", result.Error)
+	}
+}
+
+func TestRunMountWithinTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.MountTimeout = time.Second
+	m := New(config)
+	m.Run = func(ctx context.Context, name string, args ...string) (string, string, error) {
+		return "", "", nil
+	}
+
+	result := m.runMount(HostResult{Host: testHost(t.TempDir(), "a")})
+	if !result.Mounted || result.Error != nil {
+		t.Errorf("got mounted %v error %v, want mounted", result.Mounted, result.Error)
+	}
+}
+
+// fakeMounts is a CommandRunner answering ping and faking sshfs, which
+// records how many mounts of each path run at once.
+type fakeMounts struct {
+	mu      sync.Mutex
+	running map[string]int
+	most    map[string]int
+	mounts  int
+}
+
+func (f *fakeMounts) run(ctx context.Context, name string, args ...string) (string, string, error) {
+	if name == "ping" {
+		return "64 bytes from 127.0.0.1: icmp_seq=1 ttl=64 time=0.042 ms\n", "", nil
+	}
+	path := args[1]
+	f.mu.Lock()
+	f.running[path]++
+	if f.running[path] > f.most[path] {
+		f.most[path] = f.running[path]
+	}
+	f.mounts++
+	f.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	f.mu.Lock()
+	f.running[path]--
+	f.mu.Unlock()
+	return "", "", nil
+}
+
+// sshListener stands in for sshd, so the SSH port knock succeeds.
+func sshListener(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestProcessHostsParallelConcurrent(t *testing.T) {
+	port := sshListener(t)
+	dir := t.TempDir()
+
+	// Many servers, and two hosts of one server sharing a mount path
+	var hosts []Host
+	for i := 0; i < 20; i++ {
+		host := testHost(dir, "m"+strconv.Itoa(i))
+		host.Username = fmt.Sprintf("user%d", i)
+		host.Port = port
+		hosts = append(hosts, host)
+	}
+	twin := hosts[0]
+	twin.Label = "twin"
+	hosts = append(hosts, twin)
+
+	config := DefaultConfig()
+	config.RemoteInfo = false
+	m := New(config)
+	fake := &fakeMounts{running: map[string]int{}, most: map[string]int{}}
+	m.Run = fake.run
+
+	const callers = 4
+	var wg sync.WaitGroup
+	all := make([][]HostResult, callers)
+	for c := 0; c < callers; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			all[c] = m.ProcessHostsParallel(hosts)
+		}(c)
+	}
+	wg.Wait()
+
+	for c, results := range all {
+		if len(results) != len(hosts) {
+			t.Fatalf("caller %d: %d results for %d hosts", c, len(results), len(hosts))
+		}
+		for i, result := range results {
+			if result.Host.MountPath != hosts[i].MountPath || result.Host.Label != hosts[i].Label {
+				t.Errorf("caller %d: result %d is for %s %q, want %s %q", c, i,
+					result.Host.MountPath, result.Host.Label, hosts[i].MountPath, hosts[i].Label)
+			}
+			if !result.Mounted && !errors.Is(result.Error, ErrInFlight) {
+				t.Errorf("caller %d: %s neither mounted nor in flight: %v", c, result.Host.MountPath, result.Error)
+			}
+		}
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for path, most := range fake.most {
+		if most > 1 {
+			t.Errorf("%s mounted %d times at once", path, most)
+		}
+	}
+	if fake.mounts == 0 {
+		t.Error("nothing was mounted")
+	}
+}
+
+// healthMounter is a Mounter whose health checks say healthy.
+type healthMounter struct {
+	Mounter
+	healthy bool
+}
+
+func (h healthMounter) IsHealthy(mountPoint string) bool { return h.healthy }
+
+func TestEndpointStaleAsksMounter(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present")
+	if err := os.Mkdir(present, 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		healthy bool
+		want    bool
+	}{
+		{"healthy", present, true, false},
+		{"unhealthy", present, false, true},
+		{"missing", filepath.Join(dir, "missing"), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := endpointStale(healthMounter{healthy: tt.healthy}, tt.path); got != tt.want {
+				t.Errorf("endpointStale = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
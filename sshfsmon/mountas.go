@@ -0,0 +1,58 @@
+package sshfsmon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// CheckMountAs makes sure Config.MountAs can be used: the user exists and
+// sudo runs commands as them without asking for a password. Without
+// MountAs there is nothing to check.
+func (c *Config) CheckMountAs() error {
+	if c.MountAs == "" {
+		return nil
+	}
+	if _, err := user.Lookup(c.MountAs); err != nil {
+		return fmt.Errorf("--mount-as: no local user %q", c.MountAs)
+	}
+	if _, err := exec.LookPath("sudo"); err != nil {
+		return fmt.Errorf("--mount-as: sudo not found: %v", err)
+	}
+	output, err := exec.Command("sudo", "-n", "-u", c.MountAs, "true").CombinedOutput()
+	if err != nil {
+		detail := strings.TrimSpace(string(output))
+		if detail == "" {
+			detail = err.Error()
+		}
+		return fmt.Errorf("--mount-as: sudo cannot run commands as %s without a password (%s); allow it in sudoers, e.g. \"root ALL=(%s) NOPASSWD: ALL\"", c.MountAs, detail, c.MountAs)
+	}
+	return nil
+}
+
+// asMountUser returns the command line running name with args as
+// Config.MountAs through sudo, or unchanged without MountAs.
+func (m *Monitor) asMountUser(name string, args ...string) (string, []string) {
+	if m.Config.MountAs == "" {
+		return name, args
+	}
+	return "sudo", append([]string{"-n", "-u", m.Config.MountAs, "--", name}, args...)
+}
+
+// chownMountDir hands an unmounted mount point to Config.MountAs, since
+// FUSE only mounts over directories the mounting user may write to.
+func (m *Monitor) chownMountDir(path string) error {
+	if m.Config.MountAs == "" || IsMountPoint(path) {
+		return nil
+	}
+	u, err := user.Lookup(m.Config.MountAs)
+	if err != nil {
+		return err
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+	return os.Chown(path, uid, gid)
+}
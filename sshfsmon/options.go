@@ -0,0 +1,154 @@
+package sshfsmon
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SSHOptions returns the ssh settings shared by the sshfs mount and the
+// remote info probes, in key=value form.
+func (m *Monitor) SSHOptions(host Host) []string {
+	opts := []string{
+		"StrictHostKeyChecking=" + m.Config.StrictHostKey,
+		"ConnectTimeout=" + strconv.Itoa(m.Config.SSHConnectTimeout),
+	}
+	if knownHosts := m.KnownHostsPath(host); knownHosts != "" {
+		opts = append(opts, "UserKnownHostsFile="+knownHosts)
+	}
+	if host.ProxyJump != "" {
+		opts = append(opts, "ProxyJump="+host.ProxyJump)
+	}
+	if m.Config.ControlMaster {
+		opts = append(opts,
+			"ControlMaster=auto",
+			"ControlPath="+filepath.Join(m.Config.ControlDir, "cm-%r@%h:%p"),
+			"ControlPersist=60")
+	}
+	return opts
+}
+
+// PrepareControlDir creates the directory holding the ssh control sockets.
+// Anyone who can reach a socket can ride its authenticated connection, so
+// the directory is owner-only.
+func (m *Monitor) PrepareControlDir() error {
+	if err := os.MkdirAll(m.Config.ControlDir, 0700); err != nil {
+		return err
+	}
+	return os.Chmod(m.Config.ControlDir, 0700)
+}
+
+// optionSet is an ordered list of sshfs -o options in which setting a key
+// again replaces the earlier value in place, so no key appears twice.
+type optionSet struct {
+	keys   []string
+	values map[string]string
+}
+
+func (o *optionSet) set(key, value string) {
+	if o.values == nil {
+		o.values = make(map[string]string)
+	}
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// unset removes key, if set.
+func (o *optionSet) unset(key string) {
+	if _, exists := o.values[key]; !exists {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// add sets every option of a comma-separated list such as "a=1,b".
+func (o *optionSet) add(list string) {
+	for _, opt := range strings.Split(list, ",") {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			key, value, _ := strings.Cut(opt, "=")
+			o.set(key, value)
+		}
+	}
+}
+
+func (o *optionSet) String() string {
+	opts := make([]string, 0, len(o.keys))
+	for _, key := range o.keys {
+		if value := o.values[key]; value != "" {
+			opts = append(opts, key+"="+value)
+		} else {
+			opts = append(opts, key)
+		}
+	}
+	return strings.Join(opts, ",")
+}
+
+// MountOptions returns the complete -o argument for sshfs. Later sources
+// override earlier ones: the configured defaults, then global settings, then
+// Config.SSHFSOptions, then the host's own opts=. The port always comes from the port column.
+func (m *Monitor) MountOptions(host Host) string {
+	var opts optionSet
+	opts.add(m.Config.MountOptions)
+	opts.set("port", strconv.Itoa(host.Port))
+	opts.add(strings.Join(m.SSHOptions(host), ","))
+	if m.Config.AllowOther {
+		opts.set("allow_other", "")
+	}
+	// Compression and a cheaper cipher help on slow or metered links. sshfs
+	// has no bandwidth cap of its own; that needs ssh-level configuration.
+	if host.Compress {
+		opts.set("compression", "yes")
+	}
+	if host.Cipher != "" {
+		opts.set("Ciphers", host.Cipher)
+	}
+	if m.Config.DelayConnect {
+		opts.set("delay_connect", "")
+		opts.set("reconnect", "")
+	}
+	// Local ownership of the files: the host's own, else the global one
+	for _, id := range []struct{ key, host, global string }{
+		{"uid", host.UID, m.Config.UID},
+		{"gid", host.GID, m.Config.GID},
+	} {
+		if id.host != "" {
+			opts.set(id.key, id.host)
+		} else if id.global != "" {
+			opts.set(id.key, id.global)
+		}
+	}
+	// Explicit uid/gid translation tables
+	if host.UIDFile != "" || host.GIDFile != "" {
+		opts.set("idmap", "file")
+		if host.UIDFile != "" {
+			opts.set("uidfile", host.UIDFile)
+		}
+		if host.GIDFile != "" {
+			opts.set("gidfile", host.GIDFile)
+		}
+	}
+	for _, list := range m.Config.SSHFSOptions {
+		opts.add(list)
+	}
+	opts.add(host.Options)
+	// ro=true cannot be undone by opts=
+	if host.ReadOnly {
+		opts.set("ro", "")
+		opts.unset("rw")
+	}
+	opts.set("port", strconv.Itoa(host.Port))
+	if host.SSHAlias {
+		// The ssh config has the port
+		opts.unset("port")
+	}
+	return opts.String()
+}
@@ -0,0 +1,150 @@
+package sshfsmon
+
+import (
+	"strings"
+	"testing"
+)
+
+// mountOptions returns the sshfs -o options for host as a map, with "" for
+// flags without a value.
+func mountOptions(m *Monitor, host Host) map[string]string {
+	opts := make(map[string]string)
+	for _, opt := range strings.Split(m.MountOptions(host), ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		opts[key] = value
+	}
+	return opts
+}
+
+func TestMountOptionsCompression(t *testing.T) {
+	m := New(DefaultConfig())
+	host := testHost(t.TempDir(), "a")
+
+	opts := mountOptions(m, host)
+	for _, key := range []string{"compression", "Ciphers"} {
+		if value, ok := opts[key]; ok {
+			t.Errorf("default options set %s=%s", key, value)
+		}
+	}
+
+	host.Compress = true
+	if value := mountOptions(m, host)["compression"]; value != "yes" {
+		t.Errorf("compress=yes gives compression=%q, want yes", value)
+	}
+
+	host.Compress = false
+	host.Cipher = "aes128-ctr"
+	opts = mountOptions(m, host)
+	if value := opts["Ciphers"]; value != "aes128-ctr" {
+		t.Errorf("cipher= gives Ciphers=%q, want aes128-ctr", value)
+	}
+	if _, ok := opts["compression"]; ok {
+		t.Error("cipher= alone set compression")
+	}
+}
+
+func TestProxyJump(t *testing.T) {
+	m := New(DefaultConfig())
+	host := testHost(t.TempDir(), "a")
+
+	if value, ok := mountOptions(m, host)["ProxyJump"]; ok {
+		t.Errorf("default sshfs options set ProxyJump=%s", value)
+	}
+	for _, arg := range m.sshArgs(host) {
+		if strings.Contains(arg, "ProxyJump") || arg == "-J" {
+			t.Errorf("default ssh arguments carry %q", arg)
+		}
+	}
+
+	host.ProxyJump = "ops@bastion:2222"
+	if value := mountOptions(m, host)["ProxyJump"]; value != "ops@bastion:2222" {
+		t.Errorf("sshfs ProxyJump=%q, want ops@bastion:2222", value)
+	}
+	args := m.sshArgs(host)
+	found := false
+	for i, arg := range args {
+		if arg == "-o" && i+1 < len(args) && args[i+1] == "ProxyJump=ops@bastion:2222" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("ssh arguments %q lack -o ProxyJump=ops@bastion:2222", args)
+	}
+	// The destination stays the target, not the bastion
+	if last := args[len(args)-1]; last != "root@127.0.0.1" {
+		t.Errorf("ssh destination %q, want root@127.0.0.1", last)
+	}
+}
+
+func TestMountOptionsOwnership(t *testing.T) {
+	host := testHost(t.TempDir(), "a")
+	tests := []struct {
+		name               string
+		globalUID, hostUID string
+		globalGID, hostGID string
+		wantUID, wantGID   string
+	}{
+		{name: "unset"},
+		{name: "global", globalUID: "1000", globalGID: "100", wantUID: "1000", wantGID: "100"},
+		{name: "per host", hostUID: "1001", hostGID: "101", wantUID: "1001", wantGID: "101"},
+		{name: "host over global", globalUID: "1000", hostUID: "1001", globalGID: "100", wantUID: "1001", wantGID: "100"},
+		{name: "uid only", hostUID: "1001", wantUID: "1001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.UID, config.GID = tt.globalUID, tt.globalGID
+			h := host
+			h.UID, h.GID = tt.hostUID, tt.hostGID
+			opts := mountOptions(New(config), h)
+			for _, id := range []struct{ key, want string }{{"uid", tt.wantUID}, {"gid", tt.wantGID}} {
+				value, ok := opts[id.key]
+				if id.want == "" && ok {
+					t.Errorf("%s=%s set though not requested", id.key, value)
+				}
+				if id.want != "" && value != id.want {
+					t.Errorf("%s=%q, want %q", id.key, value, id.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMountOptionsIDMapFile(t *testing.T) {
+	m := New(DefaultConfig())
+	host := testHost(t.TempDir(), "a")
+
+	opts := mountOptions(m, host)
+	for _, key := range []string{"idmap", "uidfile", "gidfile"} {
+		if value, ok := opts[key]; ok {
+			t.Errorf("default options set %s=%s", key, value)
+		}
+	}
+
+	tests := []struct {
+		name, uidFile, gidFile string
+	}{
+		{"uidfile", "/etc/sshfs/nas.uids", ""},
+		{"gidfile", "", "/etc/sshfs/nas.gids"},
+		{"both", "/etc/sshfs/nas.uids", "/etc/sshfs/nas.gids"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := host
+			h.UIDFile, h.GIDFile = tt.uidFile, tt.gidFile
+			opts := mountOptions(m, h)
+			if opts["idmap"] != "file" {
+				t.Errorf("idmap=%q, want file", opts["idmap"])
+			}
+			for _, file := range []struct{ key, want string }{{"uidfile", tt.uidFile}, {"gidfile", tt.gidFile}} {
+				value, ok := opts[file.key]
+				if file.want == "" && ok {
+					t.Errorf("%s=%s set though not given", file.key, value)
+				}
+				if file.want != "" && value != file.want {
+					t.Errorf("%s=%q, want %q", file.key, value, file.want)
+				}
+			}
+		})
+	}
+}
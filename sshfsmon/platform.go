@@ -0,0 +1,73 @@
+package sshfsmon
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// platform is what differs between operating systems. Linux and macOS
+// provide currentPlatform in their mount_<os>.go file, and mount_other.go
+// a generic one for the other Unixes.
+type platform interface {
+	// pingArgs returns the arguments for sending count pings, waiting up to
+	// timeout seconds for replies.
+	pingArgs(host string, count, timeout int) []string
+
+	// unmountLadder returns the unmount commands to try in turn on a stale
+	// mount point, gentlest first.
+	unmountLadder(mountPoint string) [][]string
+
+	// isMountPoint reports whether something is mounted at path.
+	isMountPoint(path string) bool
+
+	// mountType returns the filesystem type mounted at path, "" if none
+	// or unknown.
+	mountType(path string) string
+}
+
+// IsMountPoint reports whether something is mounted at path.
+func IsMountPoint(path string) bool {
+	return currentPlatform.isMountPoint(path)
+}
+
+// MountType returns the filesystem type mounted at path, such as fuse.sshfs
+// on Linux, or "" if nothing is mounted there or the type is unknown.
+func MountType(path string) string {
+	return currentPlatform.mountType(path)
+}
+
+// Unmount unmounts mountPoint with the platform's gentlest command, which
+// refuses while the mount is in use. With Config.MountAs it runs as that
+// user, who owns the mount.
+func (m *Monitor) Unmount(mountPoint string) error {
+	ladder := currentPlatform.unmountLadder(mountPoint)
+	name, args := m.asMountUser(ladder[0][0], ladder[0][1:]...)
+	if output, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// unmount runs the platform's unmount ladder until one command succeeds.
+// With Config.MountAs the ladder runs as that user first and then as
+// ourselves, which as root can still force the mount away.
+func (m *Monitor) unmount(mountPoint string) {
+	ladder := currentPlatform.unmountLadder(mountPoint)
+	if m.Config.MountAs != "" {
+		var asUser [][]string
+		for _, args := range ladder {
+			name, rest := m.asMountUser(args[0], args[1:]...)
+			asUser = append(asUser, append([]string{name}, rest...))
+		}
+		ladder = append(asUser, ladder...)
+	}
+	for _, args := range ladder {
+		if exec.Command(args[0], args[1:]...).Run() == nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package sshfsmon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PING_COUNT is how many probes a reachability check sends by default. One
+// answer is enough, so a single lost packet does not mark a host down.
+const PING_COUNT = 2
+
+// Ping checks the host with the configured PingMethod, sending PingCount
+// probes. It reports whether any was answered, the best round-trip time in
+// milliseconds ("N/A" if unknown), and how long the whole check took. An
+// ssh config alias is probed at the address the config resolves it to.
+func (m *Monitor) Ping(host Host) (bool, string, time.Duration) {
+	start := time.Now()
+	target, viaSSH, err := m.probeTarget(host)
+	if err != nil {
+		return false, "N/A", time.Since(start)
+	}
+	reachable, rtt := m.pingTarget(host, target, viaSSH)
+	return reachable, rtt, time.Since(start)
+}
+
+// pingTarget is Ping for a host whose probeTarget is already known.
+func (m *Monitor) pingTarget(host, target Host, viaSSH bool) (bool, string) {
+	start := time.Now()
+	var reachable bool
+	var rtt string
+	if viaSSH {
+		// Only ssh knows the way there
+		reachable = m.SSHHealthy(host)
+		rtt = "N/A"
+	} else if host.ProxyJump != "" {
+		// The target is only reachable through the bastion
+		reachable = m.jumpProbe(host)
+		rtt = "N/A"
+		if reachable {
+			rtt = fmt.Sprintf("%.3f", float64(time.Since(start).Nanoseconds())/1e6)
+		}
+	} else if m.Config.PingMethod == "tcp" {
+		reachable, rtt = tcpPing(target, m.Config.PingCount, m.Config.Timeout)
+	} else {
+		reachable, rtt = icmpPing(m.runner(), target.IP, m.Config.PingCount, m.Config.Timeout)
+	}
+	return reachable, rtt
+}
+
+// icmpPing runs ping, which succeeds if any of the count echoes was
+// answered, and picks the best time out of its output.
+func icmpPing(run CommandRunner, host string, count, timeout int) (bool, string) {
+	output, _, err := run(context.Background(), "ping", currentPlatform.pingArgs(host, count, timeout)...)
+	if err != nil {
+		return false, "N/A"
+	}
+	return true, bestPingTime(output)
+}
+
+// bestPingTime returns the lowest time= value in ping output, or "N/A".
+func bestPingTime(output string) string {
+	best := "N/A"
+	bestValue := -1.0
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Split(line, "time=")
+		if len(parts) < 2 {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		if bestValue < 0 || value < bestValue {
+			best, bestValue = fields[0], value
+		}
+	}
+	return best
+}
+
+// tcpPing connects to the host's SSH port count times, for networks that
+// drop ICMP. The best connect time stands in for the round trip.
+func tcpPing(host Host, count, timeout int) (bool, string) {
+	address := net.JoinHostPort(host.IP, strconv.Itoa(host.Port))
+	var best time.Duration
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", address, time.Duration(timeout)*time.Second)
+		if err != nil {
+			continue
+		}
+		elapsed := time.Since(start)
+		conn.Close()
+		if best == 0 || elapsed < best {
+			best = elapsed
+		}
+	}
+	if best == 0 {
+		return false, "N/A"
+	}
+	return true, fmt.Sprintf("%.3f", float64(best.Nanoseconds())/1e6)
+}
+
+// jumpProbe reports whether the host's SSH port answers through its jump
+// host: ssh -W forwards the port over the bastion, and the target's sshd
+// greets with "SSH-".
+func (m *Monitor) jumpProbe(host Host) bool {
+	user, jumpHost, jumpPort, err := splitJump(host.ProxyJump)
+	if err != nil {
+		return false
+	}
+	destination := jumpHost
+	if user != "" {
+		destination = user + "@" + jumpHost
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.Config.Timeout+m.Config.SSHConnectTimeout)*time.Second)
+	defer cancel()
+	args := append(m.sshConfigArgs(), "-p", strconv.Itoa(jumpPort))
+	for _, opt := range m.SSHOptions(Host{KnownHostsFile: host.KnownHostsFile}) {
+		args = append(args, "-o", opt)
+	}
+	args = append(args, "-o", "BatchMode=yes", "-W", net.JoinHostPort(host.IP, strconv.Itoa(host.Port)), destination)
+	cmd := exec.CommandContext(ctx, m.Config.SSHPath, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return false
+	}
+	if err := cmd.Start(); err != nil {
+		return false
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	banner := make([]byte, 4)
+	_, err = io.ReadFull(stdout, banner)
+	return err == nil && string(banner) == "SSH-"
+}
+
+// PingHost sends one ICMP echo and reports whether it was answered and how
+// long the whole check took.
+func PingHost(host string, timeout int) (bool, time.Duration) {
+	start := time.Now()
+	reachable, _ := icmpPing(ExecRunner, host, 1, timeout)
+	return reachable, time.Since(start)
+}
+
+// GetPingTime returns the round-trip time of one ICMP echo, in
+// milliseconds, or "N/A".
+func GetPingTime(host string, timeout int) string {
+	_, rtt := icmpPing(ExecRunner, host, 1, timeout)
+	return rtt
+}
+
+// SSHPortOpen reports whether the host accepts TCP connections on its SSH
+// port. A host can answer ping while sshd is down or restarting.
+func SSHPortOpen(host Host, timeout int) bool {
+	address := net.JoinHostPort(host.IP, strconv.Itoa(host.Port))
+	conn, err := net.DialTimeout("tcp", address, time.Duration(timeout)*time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,50 @@
+package sshfsmon
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// READONLY_CHECK_TIMEOUT bounds the write attempt on a ro=true mount.
+const READONLY_CHECK_TIMEOUT = 5 * time.Second
+
+// CheckReadOnly verifies that a mounted ro=true host really refuses writes:
+// it tries to create a file in the mount and expects EROFS. A file that
+// does get created is removed again and the result marked as a
+// READONLY-VIOLATION. Other hosts are left alone.
+func (m *Monitor) CheckReadOnly(result *HostResult) {
+	host := result.Host
+	if !host.ReadOnly {
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		f, err := os.CreateTemp(host.MountPath, ".sshfs-connector-ro-check-*")
+		if err == nil {
+			f.Close()
+			if removeErr := os.Remove(f.Name()); removeErr != nil {
+				m.logf(host, "WARN: Could not remove read-only check file %s: %v", f.Name(), removeErr)
+			}
+		}
+		done <- err
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(READONLY_CHECK_TIMEOUT):
+		m.logf(host, "WARN: Read-only check of %s timed out", host.MountPath)
+		return
+	}
+	switch {
+	case err == nil:
+		result.ReadOnlyViolation = true
+		m.logf(host, "ERROR: READONLY-VIOLATION: %s is mounted ro=true but a test file could be written to it", host.MountPath)
+	case errors.Is(err, syscall.EROFS):
+	default:
+		m.logf(host, "WARN: Read-only check of %s inconclusive, write failed with %v rather than a read-only error", host.MountPath, err)
+	}
+}
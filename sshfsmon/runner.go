@@ -0,0 +1,36 @@
+package sshfsmon
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// CommandRunner runs name with args until it exits or ctx is done, and
+// returns what it printed on stdout and stderr.
+type CommandRunner func(ctx context.Context, name string, args ...string) (stdout, stderr string, err error)
+
+// runner returns Run, or ExecRunner when it is not set.
+func (m *Monitor) runner() CommandRunner {
+	if m.Run != nil {
+		return m.Run
+	}
+	return ExecRunner
+}
+
+// ExecRunner is the CommandRunner executing commands for real. When ctx is
+// done it kills the command's whole process group, so children such as
+// the ssh sshfs starts die with it.
+func ExecRunner(ctx context.Context, name string, args ...string) (string, string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
@@ -0,0 +1,53 @@
+package sshfsmon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SampleHosts returns a commented hosts file in the fields format that
+// documents every token ParseHostLine accepts. Its example lines are
+// commented out, so the file loads no hosts until one is added.
+func SampleHosts() string {
+	var b strings.Builder
+	b.WriteString("# SSHFS Hosts Configuration\n")
+	b.WriteString("#\n")
+	b.WriteString("# One host per line:\n")
+	b.WriteString("#\n")
+	b.WriteString("#   [user@]host mount_path [port] [remote_dir] [key=value ...]\n")
+	b.WriteString("#\n")
+	b.WriteString("#   user         remote user, root if not given\n")
+	b.WriteString("#   host         IP address or hostname of the server\n")
+	b.WriteString("#   mount_path   local mount point; a relative path is resolved against the\n")
+	b.WriteString("#                mount base (--mount-base, /root by default), - names it\n")
+	b.WriteString("#                after the remote host\n")
+	b.WriteString("#   port         ssh port, 22 if not given\n")
+	b.WriteString("#   remote_dir   remote directory to mount, /root if not given\n")
+	b.WriteString("#\n")
+	b.WriteString("# In place of remote_dir, {remote_dir:mount_path,...} (no spaces) mounts several\n")
+	b.WriteString("# directories of the server; relative mount paths in it are resolved against\n")
+	b.WriteString("# the line's mount_path.\n")
+	b.WriteString("#\n")
+	b.WriteString("# Per-host options follow as key=value tokens, in any order:\n")
+	b.WriteString("#\n")
+	width := 0
+	for _, option := range HOST_OPTIONS {
+		if n := len(option.Key) + 1 + len(option.Example); n > width {
+			width = n
+		}
+	}
+	for _, option := range HOST_OPTIONS {
+		fmt.Fprintf(&b, "#   %-*s  %s\n", width, option.Key+"="+option.Example, option.Doc)
+	}
+	b.WriteString("#\n")
+	b.WriteString("# A value with spaces goes in double quotes: healthcheck=\"test -w {mount}\"\n")
+	b.WriteString("#\n")
+	b.WriteString("# Lines starting with # are ignored. Remove the # in front of an example\n")
+	b.WriteString("# below, or add your own lines:\n")
+	b.WriteString("#\n")
+	b.WriteString("# 192.168.1.100 sshfs\n")
+	b.WriteString("# admin@192.168.1.101 /mnt/nas 2222 /srv/share name=nas compress=yes\n")
+	b.WriteString("# deploy@10.0.0.5 - 22 /var/www jump=ops@bastion.example.com mkremote=true\n")
+	b.WriteString("# admin@10.0.0.6 /mnt/h {/data:data,/logs:logs} name=h\n")
+	return b.String()
+}
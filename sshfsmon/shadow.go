@@ -0,0 +1,35 @@
+package sshfsmon
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkShadow refuses to mount over a mount directory that has files in
+// it: the mount would hide them until it goes away again, and nothing on
+// the mounted side tells that they are there. It marks the result Shadowed
+// and reports whether the mount must not go ahead. Config.AllowShadow
+// mounts anyway.
+func (m *Monitor) checkShadow(result *HostResult) bool {
+	host := result.Host
+	if m.Config.AllowShadow || IsMountPoint(host.MountPath) || dirEmpty(host.MountPath) {
+		return false
+	}
+	result.Shadowed = true
+	result.Error = fmt.Errorf("mount directory %s is not empty; mounting would hide its files", host.MountPath)
+	m.logf(host, "WARN: Not mounting %s over the files in %s, which it would hide (--allow-shadow mounts anyway)", host.IP, host.MountPath)
+	return true
+}
+
+// dirEmpty reports whether path is a directory without entries. One that
+// cannot be read counts as empty; mounting will report the real problem.
+func dirEmpty(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	// io.EOF when there are no entries
+	_, err = f.Readdirnames(1)
+	return err != nil
+}
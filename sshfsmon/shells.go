@@ -0,0 +1,123 @@
+package sshfsmon
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// remoteProbe reads one remote info field on some kind of remote shell: the
+// command to run there and, when the remote cannot shape the output itself,
+// a parse function that does so locally.
+type remoteProbe struct {
+	cmd   string
+	parse func(output string) string
+}
+
+// SHELL_PROFILES are the remote shells a host's shell= can name besides the
+// default "posix", which runs the REMOTE_FIELDS commands, each with its probe
+// for the remote info fields. A field a profile has no probe for is not
+// probed on such hosts and shows as N/A.
+//
+//	busybox  BusyBox and other stripped-down systems: single commands
+//	         reading /proc and /sys, without pipes through sed or xargs
+//	minimal  restricted or non-Unix shells, as on routers: hostname only
+//	none     no remote info probes at all
+var SHELL_PROFILES = map[string]map[string]remoteProbe{
+	"busybox": {
+		"hostname": {cmd: "hostname"},
+		"uptime":   {cmd: "cat /proc/uptime", parse: parseProcUptime},
+		"mac":      {cmd: "cat /sys/class/net/eth0/address"},
+		"loadavg":  {cmd: "cat /proc/loadavg", parse: firstFields(3)},
+		"diskfree": {cmd: "df -h /", parse: parseDFAvail},
+	},
+	"minimal": {
+		"hostname": {cmd: "hostname"},
+	},
+	"none": {},
+}
+
+// DEFAULT_SHELL is the shell of hosts without shell=.
+const DEFAULT_SHELL = "posix"
+
+// validShell reports whether shell= names a known profile.
+func validShell(shell string) bool {
+	_, ok := SHELL_PROFILES[shell]
+	return ok || shell == DEFAULT_SHELL
+}
+
+// shellNames lists the accepted shell= values, for error messages.
+func shellNames() string {
+	names := []string{DEFAULT_SHELL}
+	for name := range SHELL_PROFILES {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return strings.Join(names, ", ")
+}
+
+// probeFor returns how the field is read on the host's shell, and false if
+// it is not read there at all.
+func probeFor(host Host, field string) (remoteProbe, bool) {
+	if host.Shell == "" || host.Shell == DEFAULT_SHELL {
+		cmd, ok := REMOTE_FIELDS[field]
+		return remoteProbe{cmd: cmd}, ok
+	}
+	probe, ok := SHELL_PROFILES[host.Shell][field]
+	return probe, ok
+}
+
+// parseProcUptime turns /proc/uptime, seconds since boot, into the short
+// form uptime(1) prints, such as "3 days, 4:05" or "17 min".
+func parseProcUptime(output string) string {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return ""
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return ""
+	}
+	total := int(seconds) / 60
+	days, hours, minutes := total/(24*60), total/60%24, total%60
+
+	var clock string
+	if hours == 0 {
+		clock = fmt.Sprintf("%d min", minutes)
+	} else {
+		clock = fmt.Sprintf("%d:%02d", hours, minutes)
+	}
+	switch days {
+	case 0:
+		return clock
+	case 1:
+		return "1 day, " + clock
+	}
+	return fmt.Sprintf("%d days, %s", days, clock)
+}
+
+// firstFields keeps the first n whitespace-separated fields of the output.
+func firstFields(n int) func(string) string {
+	return func(output string) string {
+		fields := strings.Fields(output)
+		if len(fields) > n {
+			fields = fields[:n]
+		}
+		return strings.Join(fields, " ")
+	}
+}
+
+// parseDFAvail picks the available space out of df output. It counts from
+// the end of the last line, as a long filesystem name makes df wrap it.
+func parseDFAvail(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return ""
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 3 {
+		return ""
+	}
+	return fields[len(fields)-3]
+}
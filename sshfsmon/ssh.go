@@ -0,0 +1,166 @@
+package sshfsmon
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSHCommand builds an ssh invocation running remoteCmd on the host with the
+// same connection settings as the mount.
+func (m *Monitor) SSHCommand(host Host, remoteCmd string) *exec.Cmd {
+	return exec.Command(m.Config.SSHPath, append(m.sshArgs(host), remoteCmd)...)
+}
+
+// sshArgs returns the ssh arguments up to the destination, with extra ssh
+// flags placed before it. An ssh config alias gets neither user nor port,
+// so the config's own apply.
+func (m *Monitor) sshArgs(host Host, extra ...string) []string {
+	args := m.sshConfigArgs()
+	if !host.SSHAlias {
+		args = append(args, "-p", strconv.Itoa(host.Port))
+	}
+	for _, opt := range m.SSHOptions(host) {
+		args = append(args, "-o", opt)
+	}
+	args = append(args, extra...)
+	if host.SSHAlias {
+		return append(args, host.IP)
+	}
+	return append(args, fmt.Sprintf("%s@%s", host.Username, host.IP))
+}
+
+// sshConfigArgs returns the -F option for Config.SSHConfig, if set.
+func (m *Monitor) sshConfigArgs() []string {
+	if m.Config.SSHConfig == "" {
+		return nil
+	}
+	return []string{"-F", m.Config.SSHConfig}
+}
+
+// SSH_CHECK_TIMEOUT bounds the ssh health check of a host with a stale mount.
+const SSH_CHECK_TIMEOUT = 5 * time.Second
+
+// SSHHealthy reports whether ssh to the host still works. With connection
+// sharing it asks the master connection the mount rides on (ssh -O check),
+// and otherwise, or when there is no live master, logs in to run true.
+func (m *Monitor) SSHHealthy(host Host) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), SSH_CHECK_TIMEOUT)
+	defer cancel()
+	if m.Config.ControlMaster {
+		check := exec.CommandContext(ctx, m.Config.SSHPath, m.sshArgs(host, "-O", "check")...)
+		if check.Run() == nil {
+			return true
+		}
+	}
+	login := exec.CommandContext(ctx, m.Config.SSHPath, append(m.sshArgs(host), "true")...)
+	return login.Run() == nil
+}
+
+// shellQuote quotes s for the remote POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// CreateRemoteDir makes sure the host's remote directory exists.
+func (m *Monitor) CreateRemoteDir(host Host) error {
+	cmd := m.SSHCommand(host, "mkdir -p "+shellQuote(host.RemoteDir))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("failed to create remote directory %s: %s", host.RemoteDir, msg)
+		}
+		return fmt.Errorf("failed to create remote directory %s: %v", host.RemoteDir, err)
+	}
+	return nil
+}
+
+// REMOTE_FIELDS maps each remote info field to the command that produces
+// it on the remote host.
+var REMOTE_FIELDS = map[string]string{
+	"hostname": "hostname",
+	"uptime":   "uptime | sed 's/.*up \\([^,]*\\).*/\\1/' | xargs",
+	"mac":      "cat /sys/class/net/eth0/address 2>/dev/null || ip link show eth0 2>/dev/null | grep -o '[0-9a-f][0-9a-f]:[0-9a-f][0-9a-f]:[0-9a-f][0-9a-f]:[0-9a-f][0-9a-f]:[0-9a-f][0-9a-f]:[0-9a-f][0-9a-f]' | head -1",
+	"loadavg":  "cut -d' ' -f1-3 /proc/loadavg 2>/dev/null || uptime | sed 's/.*load averages*: //'",
+	"diskfree": "df -h / | awk 'NR==2 {print $4}'",
+}
+
+// DEFAULT_REMOTE_FIELDS are the remote info fields probed unless configured
+// otherwise.
+var DEFAULT_REMOTE_FIELDS = []string{"hostname", "uptime", "mac"}
+
+// GetRemoteInfo runs the probe for one of the REMOTE_FIELDS on a mounted
+// host, as its shell= profile reads it. Anything that fails, or that the
+// profile does not probe, yields "N/A"; the other fields are unaffected.
+func (m *Monitor) GetRemoteInfo(host Host, infoType string) string {
+	// Check if mounted first
+	if !IsMountPoint(host.MountPath) {
+		return "N/A"
+	}
+
+	probe, ok := probeFor(host, infoType)
+	if !ok {
+		return "N/A"
+	}
+
+	cmd := m.SSHCommand(host, probe.cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		if m.Config.Debug {
+			detail := err.Error()
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				if tail := stderrTail(string(exitErr.Stderr), STDERR_LOG_LINES); tail != "" {
+					detail = tail
+				}
+			}
+			m.logf(host, "Remote info probe %s failed on %s: %s", infoType, host.IP, detail)
+		}
+		return "N/A"
+	}
+
+	result := strings.TrimSpace(string(output))
+	if probe.parse != nil {
+		result = probe.parse(result)
+	}
+	if result == "" {
+		return "N/A"
+	}
+	return result
+}
+
+// remoteInfo runs the probes of the configured RemoteFields, concurrently
+// unless ParallelRemoteInfo is off. With RemoteInfo off, or for an rclone
+// host, which may have no shell to probe, it runs none.
+func (m *Monitor) remoteInfo(host Host) RemoteInfo {
+	var info RemoteInfo
+	if !m.Config.RemoteInfo || host.Backend == BACKEND_RCLONE {
+		return info
+	}
+	if !m.Config.ParallelRemoteInfo {
+		for _, name := range m.Config.RemoteFields {
+			if dest := info.field(name); dest != nil {
+				*dest = m.GetRemoteInfo(host, name)
+			}
+		}
+		return info
+	}
+
+	var wg sync.WaitGroup
+	for _, name := range m.Config.RemoteFields {
+		dest := info.field(name)
+		if dest == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, dest *string) {
+			defer wg.Done()
+			*dest = m.GetRemoteInfo(host, name)
+		}(name, dest)
+	}
+	wg.Wait()
+	return info
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// daemonState holds what the running daemon knows between cycles. It is
+// shared by the monitor loop and the control socket, so every access goes
+// through mu.
+//
+// The daemon's other goroutines share state too, each piece guarded on its
+// own: logMessage by logMu, recentLogs and hostLog by their own mutexes,
+// the mount watcher's pending remounts by its mu. The history writer and
+// the idle reaper are owned by a single goroutine each, and the flag
+// variables and the monitor's callbacks are set before any goroutine starts
+// and only read after. Build with -race to check changes against this.
+type daemonState struct {
+	mu        sync.Mutex
+	hosts     []sshfsmon.Host
+	results   []sshfsmon.HostResult
+	failures  map[string]int  // consecutive failed cycles, keyed by mount path
+	reaped    map[string]bool // mount paths unmounted for being idle
+	up        map[string]int  // consecutive reachable cycles, keyed by mount path
+	hung      map[string]int  // consecutive cycles a mount was hung, since the last escalation
+	escalated map[string]int  // escalations that did not unwedge a mount
+
+	// mountedAt is when each mount was made, for --mount-grace
+	mountedAt map[string]time.Time
+
+	// breakers are the open circuit breakers, keyed by mount path
+	breakers map[string]*hostBreaker
+
+	// fleet is the FLEET_* state of all hosts after the last cycle, ""
+	// before the first
+	fleet string
+
+	// lastCycle is how long the last cycle took, for --cycle-deadline
+	lastCycle cycleStats
+}
+
+// reload re-reads the hosts and claims their mount paths in the mount
+// registry. On error the previous hosts stay in place.
+func (s *daemonState) reload() error {
+	all, err := loadAllHosts()
+	if err != nil {
+		return err
+	}
+	hosts, err := selectTagged(all)
+	if err != nil {
+		return err
+	}
+	if err := claimMounts(hosts); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.hosts = hosts
+	// Keep the failure history of hosts that survived the reload
+	current := make(map[string]bool)
+	for _, host := range hosts {
+		current[host.MountPath] = true
+	}
+	for key := range s.failures {
+		if !current[key] {
+			delete(s.failures, key)
+		}
+	}
+	for key := range s.reaped {
+		if !current[key] {
+			delete(s.reaped, key)
+		}
+	}
+	for key := range s.up {
+		if !current[key] {
+			delete(s.up, key)
+		}
+	}
+	for key := range s.hung {
+		if !current[key] {
+			delete(s.hung, key)
+			delete(s.escalated, key)
+		}
+	}
+	for key := range s.mountedAt {
+		if !current[key] {
+			delete(s.mountedAt, key)
+		}
+	}
+	for key := range s.breakers {
+		if !current[key] {
+			delete(s.breakers, key)
+		}
+	}
+	s.mu.Unlock()
+	loaded := fmt.Sprintf("Loaded %d hosts from %s", len(hosts), hostsSource)
+	if len(tagFilter) > 0 {
+		loaded += fmt.Sprintf(", tagged %s", tagFilterText())
+	}
+	logMessage(loaded)
+	sweepMountDirs(all)
+	return nil
+}
+
+func (s *daemonState) currentHosts() []sshfsmon.Host {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sshfsmon.Host(nil), s.hosts...)
+}
+
+// cycleHosts returns the hosts the periodic cycle should mount, leaving out
+// reaped ones.
+func (s *daemonState) cycleHosts() []sshfsmon.Host {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hosts []sshfsmon.Host
+	for _, host := range s.hosts {
+		if !s.reaped[host.MountPath] {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+func (s *daemonState) markReaped(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reaped == nil {
+		s.reaped = make(map[string]bool)
+	}
+	s.reaped[path] = true
+}
+
+func (s *daemonState) unmarkReaped(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.reaped, path)
+}
+
+func (s *daemonState) isReaped(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reaped[path]
+}
+
+func (s *daemonState) reapedPaths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paths []string
+	for path := range s.reaped {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// clearReaped hands reaped hosts back to the periodic cycle.
+func (s *daemonState) clearReaped() {
+	s.mu.Lock()
+	s.reaped = nil
+	s.mu.Unlock()
+}
+
+// recordCycle stores the results of a monitoring cycle, updating the
+// failure counters and escalating hosts that keep failing.
+func (s *daemonState) recordCycle(results []sshfsmon.HostResult) {
+	s.mu.Lock()
+	if s.failures == nil {
+		s.failures = make(map[string]int)
+	}
+	trackFailures(results, s.failures)
+	if s.up == nil {
+		s.up = make(map[string]int)
+	}
+	for _, result := range results {
+		if result.Reachable {
+			s.up[result.Host.MountPath]++
+		} else {
+			delete(s.up, result.Host.MountPath)
+		}
+	}
+	s.recordMounts(results)
+	s.updateBreakers(results)
+	s.results = s.withSkipped(results)
+	fleet, hosts := s.fleetTransition(s.results), len(s.results)
+	s.mu.Unlock()
+
+	if fleet != "" {
+		runFleetHook(fleet, hosts)
+	}
+	for _, result := range results {
+		if failureThreshold <= 0 || result.ConsecutiveFailures < failureThreshold {
+			continue
+		}
+		logMessage(fmt.Sprintf("ERROR: %s@%s has failed %d consecutive cycles",
+			result.Host.Username, result.Host.IP, result.ConsecutiveFailures))
+		if result.ConsecutiveFailures == failureThreshold && webhookURL != "" {
+			go sendFailureAlert(result)
+		}
+	}
+}
+
+// mayMount debounces mounts: a host is mounted once it has been reachable
+// for remountAfter cycles in a row, counting the current one.
+func (s *daemonState) mayMount(host sshfsmon.Host) bool {
+	s.mu.Lock()
+	up := s.up[host.MountPath] + 1
+	s.mu.Unlock()
+	if up >= remountAfter {
+		return true
+	}
+	hostLog.log(host, fmt.Sprintf("Holding off mounting %s@%s: reachable %d of %d cycles (--remount-after)",
+		host.Username, host.IP, up, remountAfter))
+	return false
+}
+
+// mayClear debounces tear-downs: a stale mount is cleared once the host has
+// failed staleAfter cycles in a row, counting the current one.
+func (s *daemonState) mayClear(host sshfsmon.Host) bool {
+	s.mu.Lock()
+	failed := s.failures[host.MountPath] + 1
+	s.mu.Unlock()
+	if failed >= staleAfter {
+		return true
+	}
+	hostLog.log(host, fmt.Sprintf("Leaving stale mount %s in place: failed %d of %d cycles (--stale-after)",
+		host.MountPath, failed, staleAfter))
+	return false
+}
+
+// recordMounts notes when the results' new mounts were made. The caller
+// holds mu.
+func (s *daemonState) recordMounts(results []sshfsmon.HostResult) {
+	for _, result := range results {
+		if !result.Mounted || result.ExecutedCmd == sshfsmon.ALREADY_MOUNTED {
+			continue
+		}
+		if s.mountedAt == nil {
+			s.mountedAt = make(map[string]time.Time)
+		}
+		s.mountedAt[result.Host.MountPath] = time.Now()
+	}
+}
+
+// mountTime tells the monitor when the host's mount was made, for
+// --mount-grace.
+func (s *daemonState) mountTime(host sshfsmon.Host) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mountedAt[host.MountPath]
+}
+
+func (s *daemonState) latestResults() []sshfsmon.HostResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sshfsmon.HostResult{}, s.results...)
+}
+
+// remount runs mountHost for every configured host with the given IP.
+func (s *daemonState) remount(ip string) ([]sshfsmon.HostResult, error) {
+	var targets []sshfsmon.Host
+	for _, host := range s.currentHosts() {
+		if host.IP == ip {
+			targets = append(targets, host)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("host %s not configured", ip)
+	}
+
+	logMessage(fmt.Sprintf("Remount of %s requested via control socket", ip))
+	return s.refresh(targets), nil
+}
+
+// refresh runs mountHost for the given hosts now and folds the fresh results
+// into the cached ones.
+func (s *daemonState) refresh(targets []sshfsmon.Host) []sshfsmon.HostResult {
+	// An explicit remount brings reaped hosts back
+	s.mu.Lock()
+	for _, host := range targets {
+		delete(s.reaped, host.MountPath)
+	}
+	s.mu.Unlock()
+
+	fresh := make([]sshfsmon.HostResult, 0, len(targets))
+	for _, host := range targets {
+		fresh = append(fresh, monitor.MountHost(host))
+	}
+
+	s.fold(fresh)
+	return fresh
+}
+
+// fold merges results obtained outside the regular cycle into the cached
+// ones.
+func (s *daemonState) fold(fresh []sshfsmon.HostResult) {
+	s.mu.Lock()
+	for i, result := range fresh {
+		// An out-of-band success clears the streak; a failure is left for
+		// the next regular cycle to count.
+		if result.Mounted {
+			delete(s.failures, result.Host.MountPath)
+			delete(s.breakers, result.Host.MountPath)
+		}
+		fresh[i].ConsecutiveFailures = s.failures[result.Host.MountPath]
+		s.recordMounts(fresh[i : i+1])
+		for j := range s.results {
+			if s.results[j].Host.MountPath == result.Host.MountPath {
+				s.results[j] = fresh[i]
+			}
+		}
+	}
+	s.mu.Unlock()
+}
+
+// MAX_ESCALATION_BACKOFF caps how far the wait between escalations of the
+// same mount doubles.
+const MAX_ESCALATION_BACKOFF = 4
+
+// escalateHung counts the cycles each mount has been hung, mounted but not
+// answering a stat, and escalates those hung for escalateAfter cycles: their sshfs
+// is killed and the host remounted from scratch. The wait doubles after
+// every escalation that did not help. Healthy mounts are never touched, and
+// in conservative mode nothing is: hung mounts are only reported.
+func (s *daemonState) escalateHung() {
+	// Mounts still waiting to connect are not hung, nor stat'ed at all
+	awaiting := make(map[string]bool)
+	for _, result := range s.latestResults() {
+		if monitor.AwaitingConnect(result) {
+			awaiting[result.Host.MountPath] = true
+		}
+	}
+
+	// Stat every mount at once, so hung ones do not hold up the loop in turn
+	hosts := s.cycleHosts()
+	hung := make([]bool, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		if awaiting[host.MountPath] {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			hung[i] = sshfsmon.MountHung(path, sshfsmon.STAT_TIMEOUT)
+		}(i, host.MountPath)
+	}
+	wg.Wait()
+
+	var due []sshfsmon.Host
+	for i, host := range hosts {
+		path := host.MountPath
+		s.mu.Lock()
+		// A fresh mount slow to answer is not hung yet
+		if !hung[i] || monitor.InGrace(host) {
+			delete(s.hung, path)
+			delete(s.escalated, path)
+			s.mu.Unlock()
+			continue
+		}
+		if s.hung == nil {
+			s.hung = make(map[string]int)
+			s.escalated = make(map[string]int)
+		}
+		s.hung[path]++
+		cycles := s.hung[path]
+		if monitor.Config.Conservative {
+			s.mu.Unlock()
+			logMessage(fmt.Sprintf("Mount %s hung for %d cycles, left in place (conservative mode)", path, cycles))
+			continue
+		}
+		backoff := s.escalated[path]
+		if backoff > MAX_ESCALATION_BACKOFF {
+			backoff = MAX_ESCALATION_BACKOFF
+		}
+		threshold := escalateAfter << backoff
+		if cycles >= threshold {
+			s.hung[path] = 0
+			s.escalated[path]++
+		}
+		s.mu.Unlock()
+
+		if cycles < threshold {
+			logMessage(fmt.Sprintf("Mount %s hung for %d of %d cycles before escalation", path, cycles, threshold))
+			continue
+		}
+		logMessage(fmt.Sprintf("Mount %s hung for %d cycles, escalating: kill sshfs, unmount, remount", path, cycles))
+		due = append(due, host)
+	}
+
+	fresh := make([]sshfsmon.HostResult, 0, len(due))
+	for _, host := range due {
+		fresh = append(fresh, monitor.Escalate(host))
+	}
+	s.fold(fresh)
+}
+
+// trackFailures bumps the counter of every host that did not end the cycle
+// mounted, resets the rest, and copies the counts into the results.
+func trackFailures(results []sshfsmon.HostResult, failures map[string]int) {
+	for i := range results {
+		key := results[i].Host.MountPath
+		if results[i].Mounted {
+			delete(failures, key)
+		} else {
+			failures[key]++
+		}
+		results[i].ConsecutiveFailures = failures[key]
+	}
+}
+
+func sendFailureAlert(result sshfsmon.HostResult) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"event":                "host_failing",
+		"host":                 fmt.Sprintf("%s@%s", result.Host.Username, result.Host.IP),
+		"mount_path":           result.Host.MountPath,
+		"consecutive_failures": result.ConsecutiveFailures,
+		"result":               result,
+	})
+	if err != nil {
+		logMessage(fmt.Sprintf("Failed to encode alert for %s: %v", result.Host.IP, err))
+		return
+	}
+
+	client := &http.Client{Timeout: time.Duration(config.Timeout) * time.Second}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		logMessage(fmt.Sprintf("Failed to send alert for %s: %v", result.Host.IP, err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logMessage(fmt.Sprintf("Alert webhook for %s returned %s", result.Host.IP, resp.Status))
+	}
+}
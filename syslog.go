@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+// SYSLOG_TAG is the tag the daemon's syslog messages carry.
+const SYSLOG_TAG = "sshfs-monitor"
+
+// syslogFacilities are the facilities --syslog-facility accepts.
+var syslogFacilities = map[string]syslog.Priority{
+	"daemon": syslog.LOG_DAEMON,
+	"user":   syslog.LOG_USER,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+// sysLog is the daemon's syslog connection with --syslog, nil otherwise.
+var sysLog *syslog.Writer
+
+// openSyslog connects to the local syslog daemon.
+func openSyslog() (*syslog.Writer, error) {
+	return syslog.New(syslogFacilities[syslogFacility]|syslog.LOG_INFO, SYSLOG_TAG)
+}
+
+// syslogOnly reports whether --syslog replaces the log file rather than
+// adding to it, which it does unless --log-file is given as well.
+func syslogOnly() bool {
+	return useSyslog && flagSources["log-file"] == ""
+}
+
+// logDestination describes where the daemon logs, for status and usage.
+func logDestination() string {
+	if !useSyslog {
+		return logPath
+	}
+	destination := fmt.Sprintf("syslog (facility %s, tag %s)", syslogFacility, SYSLOG_TAG)
+	if !syslogOnly() {
+		destination += " and " + logPath
+	}
+	return destination
+}
+
+// writeSyslog sends a log line with the priority its level prefix calls for:
+// ERROR: and WARN: lines as errors and warnings, DEBUG: lines as debug, and
+// everything else as info.
+func writeSyslog(w *syslog.Writer, message string) {
+	switch {
+	case strings.HasPrefix(message, "ERROR:"):
+		w.Err(message)
+	case strings.HasPrefix(message, "WARN:"):
+		w.Warning(message)
+	case strings.HasPrefix(message, "DEBUG:"):
+		w.Debug(message)
+	default:
+		w.Info(message)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// exportSystemd prints a unit file for running the monitor as a service.
+//
+// The unit uses Type=simple rather than Type=forking: start runs the monitor
+// loop in the foreground and never forks, so systemd would otherwise wait for
+// a parent exit that never comes and kill the service on start timeout.
+func exportSystemd() {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot determine executable path: %v\n", err)
+		os.Exit(1)
+	}
+	// The hosts file path is relative, so the service needs the same
+	// working directory as the invocation that generated the unit
+	workDir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot determine working directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	var unit strings.Builder
+	unit.WriteString("[Unit]\n")
+	unit.WriteString("Description=SSHFS auto-connector monitor\n")
+	unit.WriteString("Wants=network-online.target\n")
+	unit.WriteString("After=network-online.target\n")
+	unit.WriteString("\n")
+	unit.WriteString("[Service]\n")
+	unit.WriteString("Type=simple\n")
+	fmt.Fprintf(&unit, "PIDFile=%s\n", pidPath)
+	fmt.Fprintf(&unit, "WorkingDirectory=%s\n", workDir)
+	// start and stop must agree on a PID file moved from the default
+	var pidArg string
+	if pidPath != PID_FILE {
+		pidArg = " --pid-file " + pidPath
+	}
+	fmt.Fprintf(&unit, "ExecStart=%s start%s\n", exe, pidArg)
+	fmt.Fprintf(&unit, "ExecStop=%s stop%s\n", exe, pidArg)
+	unit.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	unit.WriteString("Restart=on-failure\n")
+	unit.WriteString("RestartSec=5\n")
+	if unitUser != "" {
+		fmt.Fprintf(&unit, "User=%s\n", unitUser)
+	}
+	if unitGroup != "" {
+		fmt.Fprintf(&unit, "Group=%s\n", unitGroup)
+	}
+	unit.WriteString("\n")
+	unit.WriteString("[Install]\n")
+	unit.WriteString("WantedBy=multi-user.target\n")
+
+	fmt.Print(unit.String())
+}
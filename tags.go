@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// repeatedListFlag collects a flag given several times, each value itself
+// possibly a comma-separated list, as --tag prod --tag lab,backup.
+type repeatedListFlag struct {
+	list *[]string
+}
+
+func (f repeatedListFlag) String() string {
+	if f.list == nil {
+		return ""
+	}
+	return strings.Join(*f.list, ",")
+}
+
+func (f repeatedListFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f.list = append(*f.list, item)
+		}
+	}
+	return nil
+}
+
+// selectTagged keeps the hosts --tag selects: those carrying any of the
+// tags, or all of them with --tag-match all. Without --tag every host is
+// kept; with it, hosts without tags never are.
+func selectTagged(hosts []sshfsmon.Host) ([]sshfsmon.Host, error) {
+	if len(tagFilter) == 0 {
+		return hosts, nil
+	}
+	var selected []sshfsmon.Host
+	for _, host := range hosts {
+		if tagsMatch(host) {
+			selected = append(selected, host)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no hosts in %s tagged %s", sshfsmon.SourceName(hostsSource), tagFilterText())
+	}
+	return selected, nil
+}
+
+func tagsMatch(host sshfsmon.Host) bool {
+	for _, tag := range tagFilter {
+		has := host.HasTag(tag)
+		if tagMatch == "all" && !has {
+			return false
+		}
+		if tagMatch == "any" && has {
+			return true
+		}
+	}
+	return tagMatch == "all"
+}
+
+// tagFilterText describes the --tag selection, as "prod or lab".
+func tagFilterText() string {
+	join := " or "
+	if tagMatch == "all" {
+		join = " and "
+	}
+	return strings.Join(tagFilter, join)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// trustMode adds the host keys of every host in the hosts file to its
+// known_hosts file (--known-hosts, or the host's known_hosts=), so the
+// fleet can then be mounted with --strict-host-key yes. It returns
+// non-zero if any host could not be scanned.
+func trustMode() int {
+	if _, err := exec.LookPath("ssh-keyscan"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: ssh-keyscan not found: %v\n", err)
+		return EXIT_MOUNT_FAILED
+	}
+
+	hosts, err := loadHosts()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading hosts: %v\n", err)
+		return EXIT_MOUNT_FAILED
+	}
+	for _, host := range hosts {
+		if monitor.KnownHostsPath(host) == "" {
+			fmt.Fprintf(os.Stderr, "Error: %s@%s has no known_hosts file; pass --known-hosts FILE or add known_hosts= to its line\n", host.Username, host.IP)
+			return EXIT_USAGE
+		}
+	}
+
+	var added, skipped, failed int
+	for _, host := range hosts {
+		hostAdded, hostSkipped, err := monitor.TrustHost(host)
+		added += hostAdded
+		skipped += hostSkipped
+		if err != nil {
+			failed++
+			fmt.Printf("%s@%s:%d: %sFAILED%s %v\n", host.Username, host.IP, host.Port, colorRed, colorReset, err)
+			continue
+		}
+		fmt.Printf("%s@%s:%d: %d added, %d already known -> %s\n", host.Username, host.IP, host.Port, hostAdded, hostSkipped, monitor.KnownHostsPath(host))
+	}
+
+	fmt.Printf("\n%d keys added, %d already known, %d of %d hosts failed\n", added, skipped, failed, len(hosts))
+	if failed > 0 {
+		return EXIT_MOUNT_FAILED
+	}
+	if config.StrictHostKey == "no" {
+		fmt.Println("Mount with --strict-host-key yes and the same known_hosts file to verify these keys.")
+	}
+	return EXIT_MOUNTED
+}
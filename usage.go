@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DF_TIMEOUT bounds each df call, which blocks for as long as a hung sshfs
+// mount does.
+const DF_TIMEOUT = 2 * time.Second
+
+// USAGE_BAR is the width of the disk usage bar in cells, unless the
+// dashboard sizes it to the terminal.
+const USAGE_BAR = 10
+
+// renderUsage returns the disk usage bar of a mount, cells wide, like
+// "[███░░░░░░░ 30%]", or "[N/A]" if df fails or times out. With --no-df it
+// returns "".
+func renderUsage(path string, cells int) string {
+	if noDF {
+		return ""
+	}
+	percent, ok := diskUsage(path)
+	if !ok {
+		return "[N/A]"
+	}
+	filled := percent * cells / 100
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", cells-filled)
+	return fmt.Sprintf("[%s %d%%]", bar, percent)
+}
+
+// diskUsage returns the use% df reports for path.
+func diskUsage(path string) (int, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), DF_TIMEOUT)
+	defer cancel()
+
+	done := make(chan []byte, 1)
+	go func() {
+		// -P keeps each filesystem on one line, where df supports it
+		output, err := exec.CommandContext(ctx, "df", "-P", path).Output()
+		if err != nil {
+			output = nil
+		}
+		done <- output
+	}()
+
+	var output []byte
+	select {
+	case output = <-done:
+	case <-ctx.Done():
+		// A df stuck on the mount may not even die when killed; leave it
+		return 0, false
+	}
+
+	return parseDFPercent(string(output))
+}
+
+// parseDFPercent picks the use% out of df output for one path. A long
+// device name makes some df put the numbers on a line of their own, so the
+// lines after the header are read as one. sshfs mounts of servers that
+// report no sizes show "-", which, like anything else that is not a
+// percentage, gives false.
+func parseDFPercent(output string) (int, bool) {
+	lines := strings.SplitN(strings.TrimSpace(output), "\n", 2)
+	if len(lines) < 2 {
+		return 0, false
+	}
+	// Filesystem, size, used, available, use%, mounted on
+	fields := strings.Fields(lines[1])
+	if len(fields) < 6 {
+		return 0, false
+	}
+	use := fields[4]
+	if !strings.HasSuffix(use, "%") {
+		return 0, false
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(use, "%"))
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, false
+	}
+	return percent, true
+}
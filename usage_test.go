@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestParseDFPercent(t *testing.T) {
+	const header = "Filesystem     1024-blocks      Used Available Capacity Mounted on\n"
+	tests := []struct {
+		name   string
+		output string
+		want   int
+		wantOK bool
+	}{
+		{
+			name:   "normal line",
+			output: header + "root@10.0.0.5:/root/   41152736  12345678  26693532      32% /root/sshfs\n",
+			want:   32, wantOK: true,
+		},
+		{
+			name: "wrapped filesystem name",
+			output: header + "root@backup-server.example.internal:/srv/very/long/remote/path/\n" +
+				"                 41152736  12345678  26693532      87% /mnt/backup\n",
+			want: 87, wantOK: true,
+		},
+		{
+			name:   "full",
+			output: header + "root@10.0.0.5:/root/ 100 100 0 100% /root/sshfs\n",
+			want:   100, wantOK: true,
+		},
+		{
+			name:   "no sizes",
+			output: header + "root@10.0.0.5:/root/ - - - - /root/sshfs\n",
+		},
+		{
+			name:   "header only",
+			output: header,
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:   "truncated line",
+			output: header + "root@10.0.0.5:/root/ 41152736 12345678\n",
+		},
+		{
+			name:   "out of range",
+			output: header + "root@10.0.0.5:/root/ 1 1 0 250% /root/sshfs\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseDFPercent(tt.output)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseDFPercent = %d, %v; want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
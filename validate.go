@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// validateMode parses the hosts source line by line and prints an OK or
+// ERROR report for each host line. It never pings, connects or mounts, so it
+// is safe to run in CI. It returns non-zero if any line is invalid.
+func validateMode() int {
+	r, err := monitor.OpenHostsSource(hostsSource)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening hosts: %v\n", err)
+		return EXIT_MOUNT_FAILED
+	}
+	defer r.Close()
+
+	next := hostLines(r)
+	mountLines := make(map[string]int)
+	checked, invalid := 0, 0
+	var all []sshfsmon.Host
+
+	for {
+		lineNum, hosts, err := next()
+		if lineNum == 0 {
+			break
+		}
+		if len(hosts) == 0 && err == nil {
+			continue
+		}
+		if lineNum < 0 {
+			fmt.Fprintf(os.Stderr, "Error reading hosts: %v\n", err)
+			return EXIT_MOUNT_FAILED
+		}
+
+		checked++
+		all = append(all, hosts...)
+		var problems []string
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		for _, host := range hosts {
+			if first, seen := mountLines[host.MountPath]; seen {
+				problems = append(problems, fmt.Sprintf("mount path %s already used on line %d", host.MountPath, first))
+			} else {
+				mountLines[host.MountPath] = lineNum
+			}
+			problems = append(problems, checkHostFiles(host)...)
+		}
+
+		if len(problems) > 0 {
+			invalid++
+			for _, problem := range problems {
+				fmt.Printf("line %d: ERROR %s\n", lineNum, problem)
+			}
+			continue
+		}
+		for _, host := range hosts {
+			fmt.Printf("line %d: OK %s@%s:%s -> %s\n", lineNum, host.Username, host.IP, host.RemoteDir, host.MountPath)
+		}
+	}
+
+	if checked == 0 {
+		fmt.Printf("No hosts found in %s\n", hostsSource)
+		return EXIT_MOUNT_FAILED
+	}
+	// after= can name a host on any line
+	if err := sshfsmon.CheckDependencies(all); err != nil {
+		fmt.Printf("ERROR %v\n", err)
+		invalid++
+	}
+	fmt.Printf("%d line(s) checked, %d invalid\n", checked, invalid)
+	if invalid > 0 {
+		return EXIT_MOUNT_FAILED
+	}
+	return EXIT_MOUNTED
+}
+
+// hostLines returns an iterator over the host lines of r in the hosts
+// source's format. Each call returns the next line's number and its hosts,
+// several for a fields line with a target list;
+// the number is 0 at the end and -1 when r cannot be read. A TOML host is
+// numbered by its [[hosts]] line.
+func hostLines(r io.Reader) func() (int, []sshfsmon.Host, error) {
+	switch monitor.HostsFormatFor(hostsSource) {
+	case "toml":
+		reader := sshfsmon.NewHostsTOMLReader(r)
+		return func() (int, []sshfsmon.Host, error) {
+			table, err := reader.Read()
+			if err == io.EOF {
+				return 0, nil, nil
+			}
+			if parseErr, isParseErr := err.(*sshfsmon.HostsParseError); isParseErr {
+				return parseErr.Line, nil, parseErr.Err
+			}
+			if err != nil {
+				return -1, nil, err
+			}
+			host, err := monitor.ParseHostTable(table)
+			if err != nil {
+				return table.Line, nil, err
+			}
+			return table.Line, []sshfsmon.Host{host}, nil
+		}
+	case "csv":
+		reader := sshfsmon.NewHostsCSVReader(r)
+		header := true
+		return func() (int, []sshfsmon.Host, error) {
+			for {
+				record, err := reader.Read()
+				if err == io.EOF {
+					return 0, nil, nil
+				}
+				if parseErr, isParseErr := err.(*csv.ParseError); isParseErr {
+					return parseErr.Line, nil, parseErr.Err
+				}
+				if err != nil {
+					return -1, nil, err
+				}
+				if header {
+					header = false
+					continue
+				}
+				line, _ := reader.FieldPos(0)
+				host, ok, err := monitor.ParseHostRecord(record)
+				if !ok {
+					return line, nil, err
+				}
+				return line, []sshfsmon.Host{host}, nil
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	return func() (int, []sshfsmon.Host, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return -1, nil, err
+			}
+			return 0, nil, nil
+		}
+		lineNum++
+		text := scanner.Text()
+		hosts, err := monitor.CheckHostLine(text)
+		if err == nil && len(hosts) == 0 {
+			if fields := strings.Fields(text); len(fields) == 1 && !strings.HasPrefix(fields[0], "#") {
+				err = fmt.Errorf("missing mount path")
+			}
+		}
+		return lineNum, hosts, err
+	}
+}
+
+// checkHostFiles checks the files a host line refers to: its known_hosts file
+// must exist, and an IdentityFile in its opts= must not be readable by
+// others, or ssh refuses to use it.
+func checkHostFiles(host sshfsmon.Host) []string {
+	var problems []string
+	if host.KnownHostsFile != "" {
+		if _, err := os.Stat(host.KnownHostsFile); err != nil {
+			problems = append(problems, fmt.Sprintf("known_hosts: %v", err))
+		}
+	}
+	for _, option := range strings.Split(host.Options, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		if !strings.EqualFold(key, "IdentityFile") || value == "" {
+			continue
+		}
+		info, err := os.Stat(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("IdentityFile: %v", err))
+			continue
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			problems = append(problems, fmt.Sprintf("IdentityFile %s is accessible by others (mode %o), ssh will ignore it", value, info.Mode().Perm()))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"sshfs-connector/sshfsmon"
+)
+
+type sortOrder int
+
+const (
+	sortFile sortOrder = iota
+	sortStatus
+	sortPing
+	sortName
+	sortTag
+)
+
+var sortOrderNames = []string{"file", "status", "ping", "name", "tag"}
+
+// viewState is the watch display's current sort and filter selection.
+type viewState struct {
+	interactive bool
+	offlineOnly bool
+	sortBy      sortOrder
+}
+
+// handleKey applies a key press and reports whether the view changed.
+func (v *viewState) handleKey(key byte) bool {
+	switch key {
+	case 'o':
+		v.offlineOnly = !v.offlineOnly
+	case 's':
+		v.sortBy = (v.sortBy + 1) % sortOrder(len(sortOrderNames))
+	default:
+		return false
+	}
+	return true
+}
+
+func (v viewState) help() string {
+	filter := "all"
+	if v.offlineOnly {
+		filter = "offline/stale only"
+	}
+	return fmt.Sprintf("Keys: o filter (%s) | s sort (%s) | q quit", filter, sortOrderNames[v.sortBy])
+}
+
+// order returns the indexes of the results to display, filtered and sorted.
+// Indexes rather than results are returned so hosts keep their "Host N"
+// numbering from the file.
+func (v viewState) order(results []sshfsmon.HostResult) []int {
+	statuses := make([]string, len(results))
+	var indexes []int
+	for i, result := range results {
+		if v.offlineOnly || v.sortBy == sortStatus {
+			statuses[i] = monitor.Status(result)
+		}
+		if v.offlineOnly && statuses[i] == "ONLINE" {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+
+	// Problems sort first
+	rank := map[string]int{"READONLY-VIOLATION": -1, "OFFLINE": 0, "SSH-DOWN": 1, "CONN-ERR": 2, "SHADOWED": 2, "SKIPPED": 2, "STALE": 3, "DEGRADED": 4, "ONLINE": 5}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		ra, rb := results[indexes[a]], results[indexes[b]]
+		switch v.sortBy {
+		case sortStatus:
+			return rank[statuses[indexes[a]]] < rank[statuses[indexes[b]]]
+		case sortPing:
+			if ra.Reachable != rb.Reachable {
+				return ra.Reachable
+			}
+			return ra.PingTime < rb.PingTime
+		case sortName:
+			return displayName(ra.Host) < displayName(rb.Host)
+		case sortTag:
+			// Grouped by first tag, untagged hosts last
+			if (len(ra.Host.Tags) == 0) != (len(rb.Host.Tags) == 0) {
+				return len(ra.Host.Tags) > 0
+			}
+			return len(ra.Host.Tags) > 0 && ra.Host.Tags[0] < rb.Host.Tags[0]
+		}
+		return false
+	})
+	return indexes
+}
+
+// displayName is the host's label, or user@ip without one.
+func displayName(host sshfsmon.Host) string {
+	if host.Label != "" {
+		return host.Label
+	}
+	return host.Username + "@" + host.IP
+}
+
+var (
+	savedTerminal string
+	restoreOnce   sync.Once
+)
+
+// startKeyReader puts the terminal into unbuffered, no-echo mode and returns
+// a channel of key presses. It returns nil when stdin is not a terminal, in
+// which case watch runs without key controls.
+func startKeyReader() <-chan byte {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+
+	saved, err := stty("-g")
+	if err != nil {
+		return nil
+	}
+	// Keep signal generation so Ctrl+C still reaches the signal handler
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil
+	}
+	savedTerminal = strings.TrimSpace(saved)
+
+	keys := make(chan byte, 16)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			if n == 1 {
+				keys <- buf[0]
+			}
+		}
+	}()
+	return keys
+}
+
+// restoreTerminal undoes startKeyReader. It is safe to call more than once
+// and from the signal handler.
+func restoreTerminal() {
+	if savedTerminal == "" {
+		return
+	}
+	restoreOnce.Do(func() {
+		stty(savedTerminal)
+	})
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+	return string(output), err
+}
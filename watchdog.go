@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"sshfs-connector/sshfsmon"
+)
+
+// CYCLE_DEADLINE_SHARE is the share of --check-interval a cycle's mounts may
+// take without --cycle-deadline.
+const CYCLE_DEADLINE_SHARE = 0.8
+
+// cycleStats describes the daemon's last cycle, for the control socket's
+// cycle command and status.
+type cycleStats struct {
+	Seconds         float64 `json:"last_cycle_seconds"`
+	DeadlineSeconds float64 `json:"deadline_seconds"`
+	Overran         bool    `json:"overran"`             // the mounts passed the deadline
+	InFlight        int     `json:"in_flight,omitempty"` // hosts still mounting when they did
+	FinishedAt      string  `json:"finished_at,omitempty"`
+}
+
+// effectiveCycleDeadline is how long a cycle may wait for its mounts before
+// the daemon moves on: --cycle-deadline, or CYCLE_DEADLINE_SHARE of the
+// check interval.
+func effectiveCycleDeadline() time.Duration {
+	if cycleDeadline > 0 {
+		return cycleDeadline
+	}
+	return time.Duration(float64(checkInterval) * CYCLE_DEADLINE_SHARE)
+}
+
+// recordCycleTime notes how the last cycle went.
+func (s *daemonState) recordCycleTime(took time.Duration, inFlight int, overran bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastCycle = cycleStats{
+		Seconds:         took.Seconds(),
+		DeadlineSeconds: effectiveCycleDeadline().Seconds(),
+		Overran:         overran,
+		InFlight:        inFlight,
+		FinishedAt:      time.Now().Format(time.RFC3339),
+	}
+}
+
+// keepInFlight gives the hosts a cycle skipped because an abandoned cycle is
+// still mounting them their previous results, so they do not count as
+// failed.
+func (s *daemonState) keepInFlight(results []sshfsmon.HostResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := make(map[string]sshfsmon.HostResult)
+	for _, result := range s.results {
+		previous[result.Host.MountPath] = result
+	}
+	for i, result := range results {
+		if !errors.Is(result.Error, sshfsmon.ErrInFlight) {
+			continue
+		}
+		if prev, ok := previous[result.Host.MountPath]; ok {
+			results[i] = prev
+		}
+	}
+}
+
+func (s *daemonState) cycleStats() cycleStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastCycle
+}